@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/daemon"
+	"github.com/moemoe89/fetch/pkg/jobs"
+	"github.com/moemoe89/fetch/pkg/snapshot"
+)
+
+// assetSweepInterval is the interval between the sweeps of the assets of the pruned snapshots.
+const assetSweepInterval = 10 * time.Minute
+
+// runDaemon serves the JSON HTTP API to submit fetch jobs until interrupted.
+// On shutdown the running URLs are finished and the waiting ones are canceled, they are resumed on the next start.
+func runDaemon(args []string) error {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+
+	dir := flags.String("output-dir", ".", "Directory where the pages, assets and archives are saved, or an S3 bucket as s3://bucket/prefix")
+	addr := flags.String("addr", "127.0.0.1:8081", "Address to listen on")
+	workers := flags.Int("workers", runtime.NumCPU(), "Number of URLs fetched at the same time")
+	queueSize := flags.Int("queue-size", 1000, "Maximum number of URLs waiting for a worker")
+	keep := flags.Int("keep", 0, "Number of the latest snapshots to keep per URL, 0 keeps all of them")
+	keepFor := flags.String("keep-for", "", "Maximum age of the snapshots to keep per URL, e.g. 30d or 12h")
+	stateDir := flags.String("state-dir", ".fetch/daemon", "Local directory of the job journal, the unfinished jobs are resumed on start, empty disables it")
+	keepJobsFor := flags.String("keep-jobs-for", "7d", "Duration the done and failed jobs are kept in the API and the journal once finished, e.g. 7d or 12h, 0 keeps them forever")
+	shutdownWait := flags.Duration("shutdown-timeout", time.Minute, "Maximum duration of waiting for the running URLs on shutdown, the ones still running are canceled and resumed on the next start")
+
+	// The URLs are submitted by the users of the API, so the private addresses are refused by default.
+	network := networkFlags{denyPrivateNetworks: true}
+
+	network.register(flags)
+
+	flags.Usage = func() {
+		_, _ = io.WriteString(os.Stderr, "Usage: fetch daemon [flags]\n\n")
+
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	retention, err := parseRetention(*keep, *keepFor)
+	if err != nil {
+		return err
+	}
+
+	outputStorage, err := newStorage(*dir)
+	if err != nil {
+		return err
+	}
+
+	p, err := newPipeline(outputStorage, retention, false, nil, &network)
+	if err != nil {
+		return err
+	}
+
+	network.warn(os.Stderr)
+
+	jobsRetention, err := snapshot.ParseAge(*keepJobsFor)
+	if err != nil {
+		return err
+	}
+
+	opts := []jobs.Option{jobs.WithRetention(jobsRetention)}
+
+	if *stateDir != "" {
+		journal, err := jobs.OpenJournal(*stateDir)
+		if err != nil {
+			return err
+		}
+
+		opts = append(opts, jobs.WithJournal(journal))
+	}
+
+	queue, err := jobs.New(jobs.RunnerFunc(p.run), *workers, *queueSize, opts...)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           daemon.New(queue, outputStorage),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go sweepAssetsEvery(ctx, p, assetSweepInterval)
+
+	errChan := make(chan error, 1)
+
+	go func() {
+		log.Printf("daemon listening on http://%s", *addr)
+
+		errChan <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	// A second signal kills the daemon.
+	stop()
+
+	log.Println("shutting down, waiting for the running jobs")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Stops accepting requests first, so no job is submitted to the stopped queue.
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to shutdown server: %w", err)
+	}
+
+	queueCtx, cancelQueue := context.WithTimeout(context.Background(), *shutdownWait)
+	defer cancelQueue()
+
+	err = queue.Shutdown(queueCtx)
+
+	if sweepErr := p.sweepAssets(); sweepErr != nil {
+		log.Println(sweepErr)
+	}
+
+	return err
+}
+
+// sweepAssetsEvery sweeps the assets of the pruned snapshots at every interval until the context is canceled.
+func sweepAssetsEvery(ctx context.Context, p *pipeline, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.sweepAssets(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
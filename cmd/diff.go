@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/moemoe89/fetch/pkg/diff"
+	"github.com/moemoe89/fetch/pkg/fetcher"
+	"github.com/moemoe89/fetch/pkg/snapshot"
+	"github.com/moemoe89/fetch/pkg/storage"
+)
+
+// errDiffArgument represents an error message when the diff command doesn't receive a URL and at most two snapshots.
+var errDiffArgument = errors.New("expected a URL and at most two snapshots")
+
+// runDiff compares two snapshots of the URL, by default the previous one to the latest one.
+func runDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+
+	dir := flags.String("output-dir", ".", "Directory where the pages were saved, or an S3 bucket as s3://bucket/prefix")
+	report := flags.String("report", "", "Write a side-by-side HTML report into the given file")
+
+	flags.Usage = func() {
+		_, _ = io.WriteString(os.Stderr, "Usage: fetch diff [flags] URL [FROM [TO]]\n\n"+
+			"FROM and TO are latest, previous, a snapshot ID or an RFC3339 time, previous and latest by default.\n\n")
+
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() < 1 || flags.NArg() > 3 {
+		flags.Usage()
+
+		return errDiffArgument
+	}
+
+	url, from, to := flags.Arg(0), snapshot.Previous, snapshot.Latest
+
+	if flags.NArg() > 1 {
+		from = flags.Arg(1)
+	}
+
+	if flags.NArg() > 2 {
+		to = flags.Arg(2)
+	}
+
+	outputStorage, err := newStorage(*dir)
+	if err != nil {
+		return err
+	}
+
+	history := snapshot.New(outputStorage)
+
+	fromVersion, err := loadVersion(outputStorage, history, url, from)
+	if err != nil {
+		return err
+	}
+
+	toVersion, err := loadVersion(outputStorage, history, url, to)
+	if err != nil {
+		return err
+	}
+
+	result, err := diff.Compare(fromVersion, toVersion)
+	if err != nil {
+		return err
+	}
+
+	if err := diff.WriteText(os.Stdout, result); err != nil {
+		return err
+	}
+
+	if *report == "" {
+		return nil
+	}
+
+	file, err := os.Create(*report)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+
+	if err := diff.WriteReport(file, result); err != nil {
+		_ = file.Close()
+
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close report: %w", err)
+	}
+
+	return nil
+}
+
+// loadVersion reads the page and the metadata of the snapshot of the URL matching `at`.
+// The metadata is optional, snapshots fetched without --metadata only have the page.
+func loadVersion(s storage.Storage, history *snapshot.History, url, at string) (diff.Version, error) {
+	snap, err := history.Find(url, at)
+	if err != nil {
+		return diff.Version{}, err
+	}
+
+	version := diff.Version{Label: snap.ID}
+
+	version.HTML, err = s.ReadFile(path.Join(snap.Dir, snapshot.PageFilename))
+	if err != nil {
+		return diff.Version{}, fmt.Errorf("failed to read page of snapshot %s: %w", snap.ID, err)
+	}
+
+	metadataJSON, err := s.ReadFile(path.Join(snap.Dir, fetcher.MetadataFilename))
+	if errors.Is(err, storage.ErrNotExist) {
+		return version, nil
+	}
+
+	if err != nil {
+		return diff.Version{}, fmt.Errorf("failed to read metadata of snapshot %s: %w", snap.ID, err)
+	}
+
+	if err := json.Unmarshal(metadataJSON, &version.Metadata); err != nil {
+		return diff.Version{}, fmt.Errorf("failed to unmarshal metadata of snapshot %s: %w", snap.ID, err)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/moemoe89/fetch/pkg/filter"
+)
+
+// filterFlags are the flags of the filter rules skipping the pages and the assets.
+type filterFlags struct {
+	configFile    string
+	include       stringsFlag
+	exclude       stringsFlag
+	includeRegex  stringsFlag
+	excludeRegex  stringsFlag
+	allowHosts    stringsFlag
+	denyHosts     stringsFlag
+	mimeTypes     stringsFlag
+	denyMIMETypes stringsFlag
+	maxAssetSize  string
+}
+
+// register adds the filter flags to the flag set.
+func (f *filterFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.configFile, "filter-config", "", "JSON file with the filter rules, the filter flags are added to its rules")
+	flags.Var(&f.include, "include", "Fetch only the pages and assets whose URL matches the glob pattern, * matches any characters, can be repeated")
+	flags.Var(&f.exclude, "exclude", "Skip the pages and assets whose URL matches the glob pattern, can be repeated")
+	flags.Var(&f.includeRegex, "include-regex", "Fetch only the pages and assets whose URL matches the regular expression, can be repeated")
+	flags.Var(&f.excludeRegex, "exclude-regex", "Skip the pages and assets whose URL matches the regular expression, can be repeated")
+	flags.Var(&f.allowHosts, "allow-host", "Fetch only the pages and assets of the host, *.example.com matches its subdomains, can be repeated")
+	flags.Var(&f.denyHosts, "deny-host", "Skip the pages and assets of the host, can be repeated")
+	flags.Var(&f.mimeTypes, "mime-type", "Save only the pages and assets of the MIME type, image/* matches every image, can be repeated")
+	flags.Var(&f.denyMIMETypes, "deny-mime-type", "Skip the pages and assets of the MIME type, can be repeated")
+	flags.StringVar(&f.maxAssetSize, "max-asset-size", "", "Skip the assets larger than the size, e.g. 10MB")
+}
+
+// config returns the rules of the config file and the flags.
+func (f *filterFlags) config() (filter.Config, error) {
+	var config filter.Config
+
+	if f.configFile != "" {
+		var err error
+
+		config, err = filter.LoadConfig(f.configFile)
+		if err != nil {
+			return config, err
+		}
+	}
+
+	config = config.Merge(filter.Config{
+		Include:       f.include,
+		Exclude:       f.exclude,
+		IncludeRegex:  f.includeRegex,
+		ExcludeRegex:  f.excludeRegex,
+		AllowHosts:    f.allowHosts,
+		DenyHosts:     f.denyHosts,
+		MIMETypes:     f.mimeTypes,
+		DenyMIMETypes: f.denyMIMETypes,
+		MaxAssetSize:  f.maxAssetSize,
+	})
+
+	// The rules are compiled to report an invalid rule before fetching anything.
+	if _, err := filter.New(config); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
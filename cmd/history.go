@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"text/tabwriter"
+
+	"github.com/moemoe89/fetch/pkg/snapshot"
+	"github.com/moemoe89/fetch/pkg/storage"
+	"github.com/moemoe89/fetch/pkg/store"
+)
+
+// errHistoryArgument represents an error message when the history command doesn't receive exactly one URL.
+var errHistoryArgument = errors.New("expected exactly one URL argument")
+
+// runHistory lists the snapshots of the URL, or extracts one of them with its assets into a local directory.
+func runHistory(args []string) error {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+
+	dir := flags.String("output-dir", ".", "Directory where the pages were saved, or an S3 bucket as s3://bucket/prefix")
+	at := flags.String("at", snapshot.Latest, "Snapshot to extract, or to list only: latest, previous, a snapshot ID or an RFC3339 time")
+	extract := flags.String("extract", "", "Extract the snapshot and its assets into the given directory")
+
+	flags.Usage = func() {
+		_, _ = io.WriteString(os.Stderr, "Usage: fetch history [flags] URL\n\n")
+
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+
+		return errHistoryArgument
+	}
+
+	url := flags.Arg(0)
+
+	outputStorage, err := newStorage(*dir)
+	if err != nil {
+		return err
+	}
+
+	history := snapshot.New(outputStorage)
+
+	// Without --extract, every snapshot is listed unless --at selects one.
+	atSet := false
+
+	flags.Visit(func(f *flag.Flag) {
+		atSet = atSet || f.Name == "at"
+	})
+
+	if *extract == "" && !atSet {
+		index, err := history.Index(url)
+		if err != nil {
+			return err
+		}
+
+		return listSnapshots(index.Snapshots)
+	}
+
+	snap, err := history.Find(url, *at)
+	if err != nil {
+		return err
+	}
+
+	if *extract == "" {
+		return listSnapshots([]snapshot.Snapshot{snap})
+	}
+
+	return extractSnapshot(outputStorage, history, snap, *extract)
+}
+
+// listSnapshots prints the snapshots from the oldest to the latest.
+func listSnapshots(snapshots []snapshot.Snapshot) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(writer, "ID\tFETCHED AT\tDIR")
+
+	for _, snap := range snapshots {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\n", snap.ID, snap.FetchedAt.Format("Mon Jan 02 2006 15:04 MST"), snap.Dir)
+	}
+
+	return writer.Flush()
+}
+
+// extractSnapshot copies the files of the snapshot and the assets listed in its manifest into the local directory,
+// keeping the layout of the output directory so the links into the store keep working.
+func extractSnapshot(outputStorage storage.Storage, history *snapshot.History, snap snapshot.Snapshot, dir string) error {
+	names, err := history.Files(snap)
+	if err != nil {
+		return err
+	}
+
+	manifestFile := path.Join(snap.Dir, store.ManifestFilename)
+
+	manifest, err := store.New(outputStorage, storeDir).LoadManifest(manifestFile)
+	if err == nil {
+		for _, asset := range manifest.Assets {
+			names = append(names, asset.Path)
+		}
+	} else if !errors.Is(err, storage.ErrNotExist) {
+		return err
+	}
+
+	target := storage.NewLocal(dir)
+
+	for _, name := range names {
+		body, err := outputStorage.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot file: %s: %w", name, err)
+		}
+
+		if err := target.WriteFile(name, body); err != nil {
+			return fmt.Errorf("failed to extract snapshot file: %s: %w", name, err)
+		}
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "%s\n", path.Join(dir, snap.Dir, snapshot.PageFilename))
+
+	return nil
+}
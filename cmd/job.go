@@ -0,0 +1,621 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/fetcher"
+	"github.com/moemoe89/fetch/pkg/filter"
+	"github.com/moemoe89/fetch/pkg/input"
+	"github.com/moemoe89/fetch/pkg/jobs"
+	"github.com/moemoe89/fetch/pkg/output"
+	"github.com/moemoe89/fetch/pkg/snapshot"
+)
+
+// errResumeArgument represents an error message when URLs are given together with --resume.
+var errResumeArgument = errors.New("--resume continues the URLs of the job, it doesn't take URL arguments or --input")
+
+// errEmptyInput represents an error message when the input file and the sitemaps have no URL.
+var errEmptyInput = errors.New("no URL to fetch in the input or the sitemaps")
+
+// cliSettings are the flags of a CLI run saved in the journal, so --resume runs with the same flags.
+type cliSettings struct {
+	OutputDir string `json:"output_dir"`
+	Keep      int    `json:"keep"`
+	KeepFor   string `json:"keep_for"`
+	// Filter are the rules skipping the pages and the assets.
+	Filter filter.Config `json:"filter"`
+	// StrictAssets fails the page when one of its assets fails.
+	StrictAssets bool `json:"strict_assets,omitempty"`
+	// SkipOtherTypes skips the pages of another content type than the page types, instead of failing them.
+	SkipOtherTypes bool `json:"skip_other_types,omitempty"`
+	// Items are the URLs of the run with their options, in the order of the job URLs.
+	Items []input.Item `json:"items,omitempty"`
+	// Flags are the values of the request flags given to the run, see resumedFlagNames.
+	Flags map[string][]string `json:"flags,omitempty"`
+}
+
+// resumedFlagNames returns the names of the flags of the requests saved in the journal,
+// e.g. the timeouts, the proxy, the TLS flags and the concurrency, so --resume fetches with the same rules.
+func resumedFlagNames() map[string]bool {
+	flags := flag.NewFlagSet("network", flag.ContinueOnError)
+
+	var n networkFlags
+
+	n.register(flags)
+
+	names := map[string]bool{"concurrency": true}
+
+	flags.VisitAll(func(f *flag.Flag) {
+		names[f.Name] = true
+	})
+
+	return names
+}
+
+// savedFlags returns the values of the flags among the names which are set on the command line,
+// every value of a repeated flag included.
+func savedFlags(flags *flag.FlagSet, names map[string]bool) map[string][]string {
+	saved := make(map[string][]string)
+
+	flags.Visit(func(f *flag.Flag) {
+		if !names[f.Name] {
+			return
+		}
+
+		if values, ok := f.Value.(*stringsFlag); ok {
+			saved[f.Name] = append([]string(nil), *values...)
+
+			return
+		}
+
+		saved[f.Name] = []string{f.Value.String()}
+	})
+
+	return saved
+}
+
+// restoreFlags sets the saved flags which aren't set on the command line, a flag given again replaces the saved one.
+func restoreFlags(flags *flag.FlagSet, saved map[string][]string) error {
+	set := make(map[string]bool)
+
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for name, values := range saved {
+		if set[name] {
+			continue
+		}
+
+		for _, value := range values {
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("failed to restore flag --%s of the job: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// cliJob is a CLI run persisted in the journal, every finished URL and downloaded asset is recorded
+// so an interrupted run can be resumed without downloading them again.
+type cliJob struct {
+	journal  *jobs.Journal
+	job      jobs.Job
+	settings cliSettings
+	// items are the options of every URL of the job, including the links added while crawling.
+	items  []input.Item
+	assets []map[string]jobs.Asset
+	// pending are the positions of the URLs to fetch.
+	pending []int
+	// seen are the URLs of the job, so a link found while crawling is fetched once.
+	seen map[string]bool
+	// pipelines are the pipelines of every output directory of the job.
+	pipelines map[string]*pipeline
+	// filter skips the pages and the assets, nil selects everything.
+	filter *filter.Filter
+	// output writes the result of every URL, nil prints the metadata on the console instead.
+	output output.Writer
+	// network configures the requests of the run, the flags of a resumed run are restored from the journal.
+	network *networkFlags
+	// mutex guards the job results, the URLs added by the concurrent runs and the pipelines.
+	mutex sync.Mutex
+}
+
+// startJob records a new job fetching the items.
+func startJob(journal *jobs.Journal, items []input.Item, options jobs.Options, settings cliSettings) (*cliJob, error) {
+	items = dedupeItems(items)
+
+	urls := make([]string, len(items))
+	for i, item := range items {
+		urls[i] = item.URL
+	}
+
+	job, err := jobs.NewJob(urls, options)
+	if err != nil {
+		return nil, err
+	}
+
+	settings.Items = items
+
+	if err := journal.Create(job, settings); err != nil {
+		return nil, err
+	}
+
+	pending := make([]int, len(urls))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	return newCLIJob(journal, job, settings, items, make([]map[string]jobs.Asset, len(urls)), pending)
+}
+
+// dedupeItems removes the items repeating the URL and the output of a previous item, so a URL given twice,
+// e.g. as an argument and in the input file, is fetched once per output directory.
+func dedupeItems(items []input.Item) []input.Item {
+	type key struct{ url, output string }
+
+	seen := make(map[key]bool, len(items))
+	deduped := make([]input.Item, 0, len(items))
+
+	for _, item := range items {
+		if seen[key{item.URL, item.Output}] {
+			continue
+		}
+
+		seen[key{item.URL, item.Output}] = true
+
+		deduped = append(deduped, item)
+	}
+
+	return deduped
+}
+
+// resumeJob loads the job from the journal, the URLs which aren't done, including the failed ones, are fetched again.
+func resumeJob(journal *jobs.Journal, id string) (*cliJob, error) {
+	state, err := journal.Load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings cliSettings
+
+	if len(state.Settings) > 0 {
+		if err := json.Unmarshal(state.Settings, &settings); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job settings: %w", err)
+		}
+	}
+
+	items := make([]input.Item, len(state.Job.Results))
+
+	for i, result := range state.Job.Results {
+		items[i] = input.Item{URL: result.URL}
+
+		switch {
+		case i < len(state.URLSettings) && len(state.URLSettings[i]) > 0:
+			if err := json.Unmarshal(state.URLSettings[i], &items[i]); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal URL settings: %w", err)
+			}
+		case i < len(settings.Items):
+			items[i] = settings.Items[i]
+		}
+	}
+
+	return newCLIJob(journal, state.Job, settings, items, state.Assets, state.Pending())
+}
+
+// newCLIJob returns the job fetching the pending URLs.
+func newCLIJob(
+	journal *jobs.Journal,
+	job jobs.Job,
+	settings cliSettings,
+	items []input.Item,
+	assets []map[string]jobs.Asset,
+	pending []int,
+) (*cliJob, error) {
+	var rules *filter.Filter
+
+	if !settings.Filter.IsZero() {
+		var err error
+
+		rules, err = filter.New(settings.Filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool, len(job.Results))
+	for _, result := range job.Results {
+		seen[result.URL] = true
+	}
+
+	return &cliJob{
+		journal:   journal,
+		job:       job,
+		settings:  settings,
+		items:     items,
+		assets:    assets,
+		pending:   pending,
+		seen:      seen,
+		pipelines: make(map[string]*pipeline),
+		filter:    rules,
+	}, nil
+}
+
+// pipeline returns the pipeline saving the pages in the output directory of the job,
+// or in the `output` directory under it.
+func (j *cliJob) pipeline(output string) (*pipeline, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if p, ok := j.pipelines[output]; ok {
+		return p, nil
+	}
+
+	retention, err := parseRetention(j.settings.Keep, j.settings.KeepFor)
+	if err != nil {
+		return nil, err
+	}
+
+	outputDir := j.settings.OutputDir
+	if output != "" {
+		// Joined as a string, so it works for both a local directory and an S3 prefix.
+		outputDir = strings.TrimSuffix(outputDir, "/") + "/" + output
+	}
+
+	outputStorage, err := newStorage(outputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := newPipeline(outputStorage, retention, j.job.Options.Metadata, j.filter, j.network)
+	if err != nil {
+		return nil, err
+	}
+
+	p.strictAssets = j.settings.StrictAssets
+	p.skipOtherTypes = j.settings.SkipOtherTypes
+
+	j.pipelines[output] = p
+
+	return p, nil
+}
+
+// sweepAssets removes the assets of the snapshots pruned by the job from the stores of its pipelines.
+// A failed sweep only leaves unused assets in the store, so it's reported without failing the job.
+func (j *cliJob) sweepAssets() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	for _, p := range j.pipelines {
+		if err := p.sweepAssets(); err != nil {
+			_, _ = io.WriteString(os.Stderr, err.Error()+"\n")
+		}
+	}
+}
+
+// run fetches the URL at position `index` of the job and records its result in the journal.
+// The links on the same host are added to the job while the depth of the URL allows it,
+// their positions are returned to be fetched too.
+func (j *cliJob) run(ctx context.Context, index int) ([]int, error) {
+	j.mutex.Lock()
+	item := j.items[index]
+	task := jobs.Task{
+		JobID:   j.job.ID,
+		Index:   index,
+		URL:     j.job.Results[index].URL,
+		Options: j.job.Options,
+		Headers: item.Headers,
+		Assets:  j.assets[index],
+		RecordAsset: func(asset jobs.Asset) error {
+			return j.journal.RecordAsset(j.job.ID, index, asset)
+		},
+	}
+	j.mutex.Unlock()
+
+	var added []int
+
+	// The page skipped by the filter rules isn't fetched.
+	runErr := j.filter.URL(task.URL)
+
+	var p *pipeline
+
+	if runErr == nil {
+		p, runErr = j.pipeline(item.Output)
+	}
+
+	var result jobs.Result
+
+	var page fetchedPage
+
+	if runErr == nil {
+		result, page, runErr = p.runPage(ctx, task)
+
+		if runErr == nil && item.Depth > 0 {
+			added, runErr = j.addLinks(item, page)
+		}
+	}
+
+	// A URL interrupted by the end of the run is fetched again by --resume, so its failure isn't recorded.
+	if runErr != nil && ctx.Err() != nil {
+		return added, nil
+	}
+
+	j.mutex.Lock()
+
+	if errors.Is(runErr, filter.ErrSkipped) {
+		result = j.job.Skip(index, filter.Reason(runErr))
+		runErr = nil
+
+		_, _ = fmt.Fprintf(os.Stderr, "skipped %s: %s\n\n", result.URL, result.Error)
+	} else {
+		result = j.job.Finish(index, result, runErr)
+	}
+
+	j.mutex.Unlock()
+
+	if err := j.journal.Finish(j.job.ID, index, result); err != nil {
+		return added, err
+	}
+
+	if err := j.report(p, result, page); err != nil {
+		return added, err
+	}
+
+	return added, runErr
+}
+
+// report writes the result of the URL to the output, or prints the metadata of the page on the console.
+func (j *cliJob) report(p *pipeline, result jobs.Result, page fetchedPage) error {
+	if j.output == nil {
+		if page.metadata != nil {
+			_, _ = io.WriteString(os.Stderr, p.client.StringMetadata(page.metadata))
+		}
+
+		return nil
+	}
+
+	record := output.Record{
+		Version:  output.Version,
+		URL:      result.URL,
+		Status:   output.StatusDone,
+		Error:    result.Error,
+		Snapshot: result.Snapshot,
+		Page:     result.Page,
+		Archive:  result.Archive,
+		Metadata: page.metadata,
+	}
+
+	switch result.Status {
+	case jobs.StatusFailed:
+		record.Status = output.StatusFailed
+		record.ErrorType = result.ErrorType
+	case jobs.StatusSkipped:
+		record.Status = output.StatusSkipped
+	}
+
+	if !page.fetchedAt.IsZero() {
+		record.FetchedAt = &page.fetchedAt
+	}
+
+	if err := j.output.Write(record); err != nil {
+		return fmt.Errorf("failed to write result: %s: %w", result.URL, err)
+	}
+
+	return nil
+}
+
+// summary counts the URLs of the job by their status, including the links added while crawling.
+func (j *cliJob) summary() jobs.Summary {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.job.Summary()
+}
+
+// addLinks adds the links of the page on the same host to the job, one level deeper than the page.
+// The links are resolved against the final URL of the page, and its host is the host of the links.
+// The links are recorded before the page is finished, so a resumed job doesn't lose them.
+func (j *cliJob) addLinks(item input.Item, page fetchedPage) ([]int, error) {
+	links, err := fetcher.Links(bytes.NewReader(page.body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse links: %s: %w", item.URL, err)
+	}
+
+	base, err := url.Parse(page.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %s: %w", page.url, err)
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	var added []int
+
+	for _, link := range links {
+		target, err := base.Parse(link)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host != base.Host {
+			continue
+		}
+
+		target.Fragment = ""
+		target.RawFragment = ""
+
+		// The links skipped by the filter rules aren't added to the job.
+		if j.seen[target.String()] || j.filter.URL(target.String()) != nil {
+			continue
+		}
+
+		j.seen[target.String()] = true
+
+		child := input.Item{URL: target.String(), Output: item.Output, Headers: item.Headers, Depth: item.Depth - 1}
+
+		index := j.job.Add(child.URL)
+
+		j.items = append(j.items, child)
+		j.assets = append(j.assets, nil)
+
+		if err := j.journal.Add(j.job.ID, index, child.URL, child); err != nil {
+			return added, err
+		}
+
+		added = append(added, index)
+	}
+
+	return added, nil
+}
+
+// fetchedPage is a page fetched and saved by runPage.
+type fetchedPage struct {
+	// url is the final URL of the page, after its redirects.
+	url  string
+	body []byte
+	// fetchedAt is the time of the snapshot of the page.
+	fetchedAt time.Time
+	// metadata is the metadata of the page with the metadata option, nil otherwise.
+	metadata *fetcher.Metadata
+}
+
+// run fetches the URL of a job task and saves it as a new snapshot, with the options of the job.
+func (p *pipeline) run(ctx context.Context, task jobs.Task) (jobs.Result, error) {
+	result, page, err := p.runPage(ctx, task)
+
+	if page.metadata != nil {
+		_, _ = io.WriteString(os.Stderr, p.client.StringMetadata(page.metadata))
+	}
+
+	return result, err
+}
+
+// runPage fetches the URL of a job task and saves it as a new snapshot, and returns the fetched page too.
+func (p *pipeline) runPage(ctx context.Context, task jobs.Task) (jobs.Result, fetchedPage, error) {
+	jobPipeline := *p
+	jobPipeline.metadata = task.Options.Metadata
+
+	result := jobs.Result{URL: task.URL}
+
+	var page fetchedPage
+
+	client, err := p.pageClient(task.Headers)
+	if err != nil {
+		return result, page, err
+	}
+
+	fetched, err := client.Fetch(ctx, task.URL)
+	if err != nil {
+		var contentTypeErr *fetcher.ContentTypeError
+		if p.skipOtherTypes && errors.As(err, &contentTypeErr) {
+			return result, page, fmt.Errorf("%w: content type %s isn't a page type", filter.ErrSkipped, contentTypeErr.ContentType)
+		}
+
+		result.ErrorType = fetchErrorType(err)
+
+		return result, page, fmt.Errorf("failed to fetch page: %s: %w", task.URL, err)
+	}
+
+	snap, metadata, err := jobPipeline.savePage(ctx, task.URL, fetched, "", assetLog{known: task.Assets, record: task.RecordAsset})
+	if err != nil {
+		return result, page, err
+	}
+
+	page = fetchedPage{url: fetched.URL, body: fetched.Body, fetchedAt: snap.FetchedAt, metadata: metadata}
+
+	result.Snapshot = snap.ID
+	result.Page = path.Join(snap.Dir, snapshot.PageFilename)
+
+	if task.Options.Metadata {
+		result.Archive = path.Join(snap.Dir, snapshot.ZipFilename)
+		result.Metadata = path.Join(snap.Dir, fetcher.MetadataFilename)
+	}
+
+	return result, page, nil
+}
+
+// fetchErrorType returns the error type of an error of FetchPage rejecting the response,
+// the other error types are classified by the job.
+func fetchErrorType(err error) string {
+	var bodySizeErr *fetcher.BodySizeError
+
+	var contentTypeErr *fetcher.ContentTypeError
+
+	var blockedErr *fetcher.BlockedAddressError
+
+	var redirectErr *fetcher.RedirectError
+
+	var certificateErr *tls.CertificateVerificationError
+
+	var pinErr *fetcher.CertificatePinError
+
+	switch {
+	case errors.As(err, &bodySizeErr):
+		return jobs.ErrorTooLarge
+	case errors.As(err, &contentTypeErr):
+		return jobs.ErrorContentType
+	case errors.As(err, &blockedErr):
+		return jobs.ErrorBlocked
+	case errors.As(err, &redirectErr):
+		return jobs.ErrorRedirect
+	case errors.As(err, &certificateErr), errors.As(err, &pinErr):
+		return jobs.ErrorTLS
+	default:
+		return ""
+	}
+}
+
+// pageClients caches the page clients of every header set, so the URLs with the same headers share a connection pool.
+type pageClients struct {
+	mutex   sync.Mutex
+	clients map[string]fetcher.Fetcher
+}
+
+// pageClient returns the client fetching the page with the headers, the assets are fetched without them.
+func (p *pipeline) pageClient(headers map[string]string) (fetcher.Fetcher, error) {
+	if len(headers) == 0 {
+		return p.client, nil
+	}
+
+	// The keys of a map are marshaled sorted, so the same headers always have the same key.
+	key, err := json.Marshal(headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal headers: %w", err)
+	}
+
+	p.pageClients.mutex.Lock()
+	defer p.pageClients.mutex.Unlock()
+
+	if client, ok := p.pageClients.clients[string(key)]; ok {
+		return client, nil
+	}
+
+	header := make(http.Header, len(headers))
+	for name, value := range headers {
+		header.Set(name, value)
+	}
+
+	client, err := fetcher.New(append([]fetcher.Option{
+		fetcher.WithStorage(p.storage),
+		fetcher.WithHeaders(header),
+		fetcher.WithResponseCheck(p.filter.CheckPage),
+	}, p.pageOptions...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	p.pageClients.clients[string(key)] = client
+
+	return client, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/moemoe89/fetch/pkg/fetcher"
+)
+
+// errOpenArgument represents an error message when the open command doesn't receive any archive.
+var errOpenArgument = errors.New("expected minimum one archive argument")
+
+// runOpen lists the entries of the archives with their sizes and checksum status,
+// verifies them against the metadata JSON and optionally extracts them.
+func runOpen(args []string) error {
+	flags := flag.NewFlagSet("open", flag.ExitOnError)
+
+	extract := flags.String("extract", "", "Extract the verified archive into the given directory")
+	noVerify := flags.Bool("no-verify", false, "Skip verifying the archive against the checksums recorded in the metadata")
+
+	flags.Usage = func() {
+		_, _ = io.WriteString(os.Stderr, "Usage: fetch open [flags] ARCHIVE...\n\n")
+
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+
+		return errOpenArgument
+	}
+
+	for _, filename := range flags.Args() {
+		if err := openArchive(filename, *extract, !*noVerify); err != nil {
+			return fmt.Errorf("failed to open archive: %s: %w", filename, err)
+		}
+	}
+
+	return nil
+}
+
+// openArchive prints the archive entries, then verifies and extracts the archive.
+func openArchive(filename, extractDir string, verify bool) error {
+	archive, err := fetcher.OpenArchive(filename)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = archive.Close() }()
+
+	entries, err := archive.Entries()
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "%s\n", filename)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(writer, "NAME\tSIZE\tCOMPRESSED\tSTATUS")
+
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(writer, "%s\t%d\t%d\t%s\n", entry.Name, entry.Size, entry.CompressedSize, entry.Status)
+	}
+
+	_ = writer.Flush()
+
+	_, _ = io.WriteString(os.Stdout, "\n")
+
+	if verify {
+		if err := archive.Verify(); err != nil {
+			return err
+		}
+	}
+
+	if extractDir == "" {
+		return nil
+	}
+
+	return archive.Extract(extractDir)
+}
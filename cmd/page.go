@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/fetcher"
+	"github.com/moemoe89/fetch/pkg/filter"
+	"github.com/moemoe89/fetch/pkg/jobs"
+	"github.com/moemoe89/fetch/pkg/snapshot"
+	"github.com/moemoe89/fetch/pkg/storage"
+	"github.com/moemoe89/fetch/pkg/store"
+	"github.com/moemoe89/fetch/pkg/utils"
+)
+
+// Layout of the output directory, every URL has its own directory built by utils.URLToPath.
+// The names start with `_` so they never collide with the directory of another URL.
+const (
+	// storeDir is the directory of the content-addressed store shared by all pages.
+	storeDir = "_store"
+)
+
+// pipeline holds the dependencies to fetch and archive the pages.
+type pipeline struct {
+	client fetcher.Fetcher
+	// assetClient fetches the assets, its responses are checked by the asset filter rules.
+	assetClient fetcher.Fetcher
+	storage     storage.Storage
+	store       *store.Store
+	history     *snapshot.History
+	retention   snapshot.Retention
+	// assets collects the assets of the pruned snapshots, it's shared by the copies of the pipeline.
+	assets *assetCollector
+	// metadata fetches the assets, saves the metadata and zips the page.
+	metadata bool
+	// filter skips the pages and the assets, nil selects everything.
+	filter *filter.Filter
+	// strictAssets fails the page when an asset fails, instead of saving the page with the original link of the asset.
+	strictAssets bool
+	// skipOtherTypes skips the pages of another content type than the page types, instead of failing them.
+	skipOtherTypes bool
+	// pageOptions configure the requests of the page clients, e.g. the timeouts and the page types.
+	pageOptions []fetcher.Option
+	// pageClients are the page clients of the URLs with headers, shared by the copies of the pipeline.
+	pageClients *pageClients
+}
+
+// assetCollector collects the assets of the pruned snapshots, which sweepAssets removes from the store
+// once no snapshot references them anymore.
+type assetCollector struct {
+	// saving is held for reading while a snapshot is saved and for writing while the assets are swept,
+	// so an asset reused by a snapshot being saved is never removed.
+	saving sync.RWMutex
+	// mutex guards the candidates.
+	mutex      sync.Mutex
+	candidates map[string]bool
+}
+
+// assetLog remembers the assets downloaded for a page by a job, so a resumed job doesn't download them again.
+// The zero value remembers nothing.
+type assetLog struct {
+	// known are the assets downloaded by a previous run of the job, keyed by the asset URL as referenced in the page.
+	known map[string]jobs.Asset
+	// record persists a downloaded asset, nil if the job isn't persisted.
+	record func(asset jobs.Asset) error
+}
+
+// savePage saves the fetched page as a new snapshot of the URL with the given content hash, which may be empty.
+// The snapshot is keyed by the requested URL even if it's redirected, so the history of the URL stays stable.
+// The metadata of the page is returned with the metadata option, nil otherwise.
+// The files of the snapshot are removed if saving it fails or the context is canceled.
+func (p *pipeline) savePage(
+	ctx context.Context,
+	url string,
+	page *fetcher.Page,
+	contentHash string,
+	assets assetLog,
+) (snapshot.Snapshot, *fetcher.Metadata, error) {
+	p.assets.saving.RLock()
+	defer p.assets.saving.RUnlock()
+
+	// Every fetch is saved in its own snapshot directory of the URL.
+	snap, previous, err := p.history.Begin(url, time.Now())
+	if err != nil {
+		return snap, nil, fmt.Errorf("failed to begin snapshot: %s: %w", url, err)
+	}
+
+	snap.ContentHash = contentHash
+
+	metadata, err := p.writeSnapshot(ctx, url, page, snap, previous, assets)
+	if err != nil {
+		// The snapshot isn't committed, so its partial files are never listed.
+		if discardErr := p.history.Discard(snap); discardErr != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to discard snapshot: %s: %v\n", url, discardErr)
+		}
+
+		return snap, nil, err
+	}
+
+	err = p.commitSnapshot(url, snap)
+	if err != nil {
+		return snap, nil, err
+	}
+
+	return snap, metadata, nil
+}
+
+// writeSnapshot writes the files of the snapshot: the page, and with the metadata option its assets,
+// metadata, manifest and archive.
+func (p *pipeline) writeSnapshot(
+	ctx context.Context,
+	url string,
+	page *fetcher.Page,
+	snap snapshot.Snapshot,
+	previous *snapshot.Snapshot,
+	assets assetLog,
+) (*fetcher.Metadata, error) {
+	client := p.client
+
+	dir := snap.Dir
+
+	htmlFile := path.Join(dir, snapshot.PageFilename)
+
+	// Stop here if the argument doesn't includes metadata.
+	if !p.metadata {
+		err := client.SavePage(htmlFile, page.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save page: %s: %w", url, err)
+		}
+
+		return nil, nil
+	}
+
+	zipFile := path.Join(dir, snapshot.ZipFilename)
+	metadataFile := path.Join(dir, fetcher.MetadataFilename)
+	manifestFile := path.Join(dir, store.ManifestFilename)
+
+	// Extract metadata.
+	metadata, err := client.ExtractMetadata(ctx, url, metadataFile, bytes.NewReader(page.Body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract metadata: %s: %w", url, err)
+	}
+
+	// The assets of a redirected page are resolved against its final URL.
+	if page.URL != url {
+		metadata.FinalURL = page.URL
+	}
+
+	metadata.Redirects = page.Redirects
+	metadata.TLS = page.TLS
+
+	// The last fetch is the time of the previous snapshot.
+	metadata.LastFetch = time.Time{}
+	if previous != nil {
+		metadata.LastFetch = previous.FetchedAt
+	}
+
+	metadata.Checksums = make(map[string]string)
+	metadata.AssetDigests = make(map[string]string)
+
+	manifest := &store.Manifest{Site: url}
+
+	newBody := string(page.Body)
+
+	// A failed asset only fails the page in strict mode, otherwise the page is saved with its original link.
+	newBody, err = fetchAssets(ctx, p.assetClient, p.store, p.filter, metadata, manifest, dir, newBody, assets)
+	if err != nil {
+		// The assets interrupted by the cancellation aren't failed, the page is fetched again when the job is resumed.
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to fetch assets: %s: %w", url, ctx.Err())
+		}
+
+		if p.strictAssets {
+			return nil, fmt.Errorf("failed to fetch assets: %s: %w", url, err)
+		}
+
+		for _, failed := range metadata.Failed {
+			_, _ = fmt.Fprintf(os.Stderr, "failed asset %s: %s\n", failed.URL, failed.Reason)
+		}
+	}
+
+	err = p.store.SaveManifest(manifest, manifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save manifest: %s: %w", url, err)
+	}
+
+	// Save HTML page.
+	err = client.SavePage(htmlFile, []byte(newBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save page: %s: %w", url, err)
+	}
+
+	err = recordChecksum(metadata, htmlFile, fetcher.Checksum([]byte(newBody)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Save the checksums to verify the archive later.
+	err = client.SaveMetadata(metadata, metadataFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save metadata: %s: %w", url, err)
+	}
+
+	// Zip HTML file, metadata and the assets from the store.
+	filePaths := []string{htmlFile, metadataFile, manifestFile}
+	for _, asset := range manifest.Assets {
+		filePaths = append(filePaths, asset.Path)
+	}
+
+	err = client.Zip(ctx, zipFile, filePaths, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to zip page: %s: %w", url, err)
+	}
+
+	return metadata, nil
+}
+
+// commitSnapshot adds the snapshot to the index of the URL and removes the snapshots out of the retention policy.
+// The assets of the removed snapshots are collected for sweepAssets.
+func (p *pipeline) commitSnapshot(url string, snap snapshot.Snapshot) error {
+	err := p.history.Commit(url, snap)
+	if err != nil {
+		return fmt.Errorf("failed to commit snapshot: %s: %w", url, err)
+	}
+
+	if p.retention == (snapshot.Retention{}) {
+		return nil
+	}
+
+	now := time.Now()
+
+	index, err := p.history.Index(url)
+	if err != nil {
+		return fmt.Errorf("failed to prune snapshots: %s: %w", url, err)
+	}
+
+	// The manifests are read before the snapshot directories are removed.
+	var candidates []string
+
+	for _, expired := range p.retention.Expired(index, now) {
+		manifest, err := p.store.LoadManifest(path.Join(expired.Dir, store.ManifestFilename))
+		if errors.Is(err, storage.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to prune snapshots: %s: %w", url, err)
+		}
+
+		for _, asset := range manifest.Assets {
+			candidates = append(candidates, asset.Path)
+		}
+	}
+
+	_, err = p.history.Prune(url, p.retention, now)
+	if err != nil {
+		return fmt.Errorf("failed to prune snapshots: %s: %w", url, err)
+	}
+
+	p.assets.mutex.Lock()
+	defer p.assets.mutex.Unlock()
+
+	for _, candidate := range candidates {
+		p.assets.candidates[candidate] = true
+	}
+
+	return nil
+}
+
+// sweepAssets removes the collected assets of the pruned snapshots which no snapshot references anymore.
+// It waits for the snapshots being saved, an asset they reuse is referenced by their manifest once they're saved.
+func (p *pipeline) sweepAssets() error {
+	p.assets.saving.Lock()
+	defer p.assets.saving.Unlock()
+
+	p.assets.mutex.Lock()
+	defer p.assets.mutex.Unlock()
+
+	candidates := make([]string, 0, len(p.assets.candidates))
+	for candidate := range p.assets.candidates {
+		candidates = append(candidates, candidate)
+	}
+
+	sort.Strings(candidates)
+
+	_, err := p.store.Sweep(candidates)
+	if err != nil {
+		return fmt.Errorf("failed to sweep assets: %w", err)
+	}
+
+	p.assets.candidates = make(map[string]bool)
+
+	return nil
+}
+
+// fetchAssets saves the assets of the page in the store and links the page to them.
+// The assets skipped by the filter rules are recorded in the metadata and keep their original link.
+// The assets which fail are recorded in the metadata and keep their original link too,
+// the returned body is complete and the error joins the errors of every failed asset.
+func fetchAssets(
+	ctx context.Context,
+	client fetcher.Fetcher,
+	assetStore *store.Store,
+	rules *filter.Filter,
+	metadata *fetcher.Metadata,
+	manifest *store.Manifest,
+	dir, newBody string,
+	assets assetLog,
+) (string, error) {
+	var wg sync.WaitGroup
+
+	var mutex sync.Mutex
+
+	var errs []error
+
+	// The same asset can be referenced many times in the page.
+	seen := make(map[string]bool, len(metadata.Assets))
+
+	// Fetch the assets with concurrency.
+	for _, asset := range metadata.Assets {
+		if seen[asset] {
+			continue
+		}
+
+		seen[asset] = true
+
+		wg.Add(1)
+
+		go func(asset string) {
+			defer wg.Done()
+
+			// Sometimes URL not contains full URL e.g. /dir/image.png or image.png
+			// To download the assets, resolve it against the final URL of the page.
+			// e.g. www.example.com/dir/image.png
+			wrapAsset := utils.ResolveURL(metadata.PageURL(), asset)
+
+			err := rules.URL(wrapAsset)
+
+			var blob store.Blob
+
+			if err == nil {
+				blob, err = storeAsset(ctx, client, assetStore, rules, assets, asset, wrapAsset)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			// The skipped asset keeps its original link in the page.
+			if errors.Is(err, filter.ErrSkipped) {
+				metadata.Skipped = append(metadata.Skipped, fetcher.Skipped{URL: asset, Reason: filter.Reason(err)})
+
+				return
+			}
+
+			// The failed asset keeps its original link in the page too.
+			if err != nil {
+				failed := fetcher.FailedAsset{URL: asset, Reason: err.Error()}
+
+				var statusErr *fetcher.StatusError
+				if errors.As(err, &statusErr) {
+					failed.Status = statusErr.StatusCode
+				}
+
+				metadata.Failed = append(metadata.Failed, failed)
+				errs = append(errs, err)
+
+				return
+			}
+
+			// The link is relative to the page directory, so the page can be opened from disk.
+			newBody = strings.ReplaceAll(newBody, asset, utils.RelativePath(dir, blob.Path))
+
+			manifest.Add(asset, blob)
+
+			metadata.AssetDigests[asset] = blob.Digest
+
+			// The digest of the blob is the SHA-256 checksum of the asset.
+			err = recordChecksum(metadata, blob.Path, blob.Digest)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}(asset)
+	}
+
+	wg.Wait()
+
+	// The assets are skipped and failed concurrently, they're sorted so the metadata is stable.
+	sort.Slice(metadata.Skipped, func(i, j int) bool {
+		return metadata.Skipped[i].URL < metadata.Skipped[j].URL
+	})
+
+	sort.Slice(metadata.Failed, func(i, j int) bool {
+		return metadata.Failed[i].URL < metadata.Failed[j].URL
+	})
+
+	return newBody, errors.Join(errs...)
+}
+
+// storeAsset fetches the asset and saves it to the store, identical assets are stored once.
+// An asset already downloaded by a previous run of the job is reused if it's still in the store.
+func storeAsset(
+	ctx context.Context,
+	client fetcher.Fetcher,
+	assetStore *store.Store,
+	rules *filter.Filter,
+	assets assetLog,
+	asset, wrapAsset string,
+) (store.Blob, error) {
+	if known, ok := assets.known[asset]; ok {
+		blob, err := assetStore.Stat(known.Digest, store.Ext(wrapAsset))
+		if err == nil {
+			return blob, nil
+		}
+	}
+
+	// The download of an asset larger than the max asset size is aborted, the asset is skipped.
+	body, err := client.FetchPage(ctx, wrapAsset)
+
+	var bodySizeErr *fetcher.BodySizeError
+	if errors.As(err, &bodySizeErr) && bodySizeErr.Limit == rules.MaxAssetSize() {
+		return store.Blob{}, fmt.Errorf("%w: size is larger than %d bytes", filter.ErrSkipped, bodySizeErr.Limit)
+	}
+
+	if err != nil {
+		return store.Blob{}, fmt.Errorf("failed to fetch page: %s: %w", wrapAsset, err)
+	}
+
+	blob, err := assetStore.Put(body, store.Ext(wrapAsset))
+	if err != nil {
+		return store.Blob{}, fmt.Errorf("failed to store asset: %s: %w", wrapAsset, err)
+	}
+
+	if assets.record != nil {
+		err = assets.record(jobs.Asset{URL: asset, Digest: blob.Digest, Path: blob.Path, Size: blob.Size})
+		if err != nil {
+			return store.Blob{}, fmt.Errorf("failed to record asset: %s: %w", wrapAsset, err)
+		}
+	}
+
+	return blob, nil
+}
+
+// recordChecksum records the checksum of the file under its zip entry name.
+func recordChecksum(metadata *fetcher.Metadata, filePath, checksum string) error {
+	name, err := fetcher.EntryName(".", filePath)
+	if err != nil {
+		return fmt.Errorf("failed to record checksum: %s: %w", filePath, err)
+	}
+
+	metadata.Checksums[name] = checksum
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/server"
+)
+
+const (
+	// readHeaderTimeout is the time allowed to read the request headers of the serve command.
+	readHeaderTimeout = 10 * time.Second
+	// shutdownTimeout is the time given to the in-flight requests when the serve command stops.
+	shutdownTimeout = 5 * time.Second
+)
+
+// runServe serves the archived pages of the output directory over HTTP until interrupted.
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+
+	dir := flags.String("output-dir", ".", "Directory where the pages were saved, or an S3 bucket as s3://bucket/prefix")
+	addr := flags.String("addr", "127.0.0.1:8080", "Address to listen on")
+
+	flags.Usage = func() {
+		_, _ = io.WriteString(os.Stderr, "Usage: fetch serve [flags]\n\n")
+
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	outputStorage, err := newStorage(*dir)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           server.New(outputStorage),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errChan := make(chan error, 1)
+
+	go func() {
+		log.Printf("serving %s on http://%s", *dir, *addr)
+
+		errChan <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to shutdown server: %w", err)
+	}
+
+	return nil
+}
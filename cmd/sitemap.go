@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/fetcher"
+	"github.com/moemoe89/fetch/pkg/input"
+	"github.com/moemoe89/fetch/pkg/sitemap"
+	"github.com/moemoe89/fetch/pkg/snapshot"
+)
+
+// errInvalidSince represents an error message when --sitemap-since is neither a date, an RFC3339 time nor an age.
+var errInvalidSince = errors.New("invalid --sitemap-since, expected a date, an RFC3339 time or an age such as 30d")
+
+// sitemapSource are the sitemaps the URLs are read from, set by the --sitemap flags.
+type sitemapSource struct {
+	urls  stringsFlag
+	since string
+	match stringsFlag
+}
+
+// items reads the URLs of the sitemaps selected by the filter flags, the sitemaps are fetched with the options.
+func (s *sitemapSource) items(ctx context.Context, now time.Time, options []fetcher.Option) ([]input.Item, error) {
+	if len(s.urls) == 0 {
+		return nil, nil
+	}
+
+	filter, err := s.filter(now)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := fetcher.New(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []input.Item
+
+	for _, sitemapURL := range s.urls {
+		if err := input.ValidateURL(sitemapURL); err != nil {
+			return nil, err
+		}
+
+		entries, err := sitemap.Read(ctx, client, sitemapURL, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			items = append(items, input.Item{URL: entry.URL})
+		}
+	}
+
+	return items, nil
+}
+
+// filter builds the filter of the sitemap entries from the flags.
+func (s *sitemapSource) filter(now time.Time) (sitemap.Filter, error) {
+	var filter sitemap.Filter
+
+	for _, match := range s.match {
+		pattern, err := regexp.Compile(match)
+		if err != nil {
+			return filter, fmt.Errorf("failed to compile --sitemap-match: %w", err)
+		}
+
+		filter.Match = append(filter.Match, pattern)
+	}
+
+	if s.since == "" {
+		return filter, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if since, err := time.Parse(layout, s.since); err == nil {
+			filter.Since = since
+
+			return filter, nil
+		}
+	}
+
+	age, err := snapshot.ParseAge(s.since)
+	if err != nil {
+		return filter, fmt.Errorf("%w: %s", errInvalidSince, s.since)
+	}
+
+	filter.Since = now.Add(-age)
+
+	return filter, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/storage"
+)
+
+// errInvalidOutputDir represents an error message when the output dir is an invalid S3 URL.
+var errInvalidOutputDir = errors.New("invalid output dir")
+
+// newStorage returns the storage of the output dir.
+// The output dir is either a local directory or an S3 bucket as `s3://bucket/prefix`,
+// the S3 endpoint, credentials and request timeout are read from the environment variables.
+func newStorage(outputDir string) (storage.Storage, error) {
+	if !strings.HasPrefix(outputDir, "s3://") {
+		return storage.NewLocal(outputDir), nil
+	}
+
+	parsed, err := url.Parse(outputDir)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: %s: expected s3://bucket/prefix", errInvalidOutputDir, outputDir)
+	}
+
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://s3.amazonaws.com"
+	}
+
+	var timeout time.Duration
+
+	if value := os.Getenv("S3_TIMEOUT"); value != "" {
+		timeout, err = time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("%w: invalid S3_TIMEOUT: %s", errInvalidOutputDir, value)
+		}
+	}
+
+	s3, err := storage.NewS3(storage.S3Config{
+		Endpoint:        endpoint,
+		Region:          os.Getenv("AWS_REGION"),
+		Bucket:          parsed.Host,
+		Prefix:          parsed.Path,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Timeout:         timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 storage: %w", err)
+	}
+
+	return s3, nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/fetcher"
+	"github.com/moemoe89/fetch/pkg/watch"
+)
+
+var (
+	// errWatchArgument represents an error message when the watch command doesn't receive any URL.
+	errWatchArgument = errors.New("expected minimum one URL argument")
+	// errWatchSchedule represents an error message when both the interval and the cron schedule are set.
+	errWatchSchedule = errors.New("--interval and --cron can't be used together")
+)
+
+// webhookTimeout is the timeout of a webhook notification.
+const webhookTimeout = 10 * time.Second
+
+// stringsFlag is a flag which can be repeated, e.g. `--strip .ad --strip time`.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+
+	return nil
+}
+
+// watcher checks the watched pages and notifies their changes.
+type watcher struct {
+	pipeline *pipeline
+	// client fetches the pages, a page with an error status fails the check instead of being saved.
+	client    fetcher.Fetcher
+	stripper  *watch.Stripper
+	events    watch.Notifier
+	notifiers []watch.Notifier
+}
+
+// runWatch re-fetches the URLs on a schedule and saves a new snapshot only when the page changed.
+func runWatch(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+
+	dir := flags.String("output-dir", ".", "Directory where the pages, assets and archives are saved, or an S3 bucket as s3://bucket/prefix")
+	withMetadata := flags.Bool("metadata", false, "Fetch the assets, save the metadata and zip every new snapshot")
+	keep := flags.Int("keep", 0, "Number of the latest snapshots to keep per URL, 0 keeps all of them")
+	keepFor := flags.String("keep-for", "", "Maximum age of the snapshots to keep per URL, e.g. 30d or 12h")
+	interval := flags.Duration("interval", 5*time.Minute, "Interval between the checks")
+	cronSpec := flags.String("cron", "", "Cron schedule of the checks instead of the interval, e.g. \"*/5 * * * *\"")
+	webhook := flags.String("webhook", "", "POST the change and error events as JSON to the URL")
+	command := flags.String("exec", "", "Run the shell command on every change and error event, the event JSON is written to its stdin")
+	events := flags.Bool("json", false, "Print every event as a JSON line on stdout, including the unchanged ones")
+
+	var strip stringsFlag
+
+	flags.Var(&strip, "strip", "CSS selector of the volatile elements ignored when comparing the pages, can be repeated")
+
+	var network networkFlags
+
+	network.register(flags)
+
+	flags.Usage = func() {
+		_, _ = io.WriteString(os.Stderr, "Usage: fetch watch [flags] URL...\n\n")
+
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+
+		return errWatchArgument
+	}
+
+	schedule, err := parseSchedule(flags, *interval, *cronSpec)
+	if err != nil {
+		return err
+	}
+
+	stripper, err := watch.NewStripper(strip)
+	if err != nil {
+		return err
+	}
+
+	retention, err := parseRetention(*keep, *keepFor)
+	if err != nil {
+		return err
+	}
+
+	outputStorage, err := newStorage(*dir)
+	if err != nil {
+		return err
+	}
+
+	p, err := newPipeline(outputStorage, retention, *withMetadata, nil, &network)
+	if err != nil {
+		return err
+	}
+
+	client, err := fetcher.New(append([]fetcher.Option{
+		fetcher.WithStorage(outputStorage),
+		fetcher.WithResponseCheck(fetcher.CheckStatus),
+	}, p.pageOptions...)...)
+	if err != nil {
+		return err
+	}
+
+	network.warn(os.Stderr)
+
+	w := &watcher{pipeline: p, client: client, stripper: stripper}
+
+	if *events {
+		w.events = watch.NewJSONLines(os.Stdout)
+	}
+
+	if *webhook != "" {
+		w.notifiers = append(w.notifiers, watch.NewWebhook(*webhook, &http.Client{Timeout: webhookTimeout}))
+	}
+
+	if *command != "" {
+		w.notifiers = append(w.notifiers, watch.NewCommand(*command))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	urls := flags.Args()
+
+	watch.Run(ctx, schedule, func(ctx context.Context) {
+		var wg sync.WaitGroup
+
+		for _, url := range urls {
+			wg.Add(1)
+
+			go func(url string) {
+				defer wg.Done()
+
+				w.notify(ctx, w.check(ctx, url))
+			}(url)
+		}
+
+		wg.Wait()
+
+		if err := p.sweepAssets(); err != nil {
+			_, _ = io.WriteString(os.Stderr, err.Error()+"\n")
+		}
+	})
+
+	return nil
+}
+
+// parseSchedule returns the cron schedule if it's set, the interval otherwise.
+func parseSchedule(flags *flag.FlagSet, interval time.Duration, cronSpec string) (watch.Schedule, error) {
+	if cronSpec == "" {
+		return watch.Every(interval)
+	}
+
+	intervalSet := false
+
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "interval" {
+			intervalSet = true
+		}
+	})
+
+	if intervalSet {
+		return nil, errWatchSchedule
+	}
+
+	return watch.ParseCron(cronSpec)
+}
+
+// check fetches the page and saves a new snapshot if its content hash differs from the latest snapshot.
+func (w *watcher) check(ctx context.Context, url string) watch.Event {
+	event := watch.Event{URL: url, Time: time.Now().UTC()}
+
+	fail := func(err error) watch.Event {
+		event.Type = watch.EventError
+		event.Error = err.Error()
+
+		return event
+	}
+
+	// An error page, e.g. a `503` maintenance page, is an error event, it never replaces the latest snapshot.
+	page, err := w.client.Fetch(ctx, url)
+	if err != nil {
+		return fail(fmt.Errorf("failed to fetch page: %s: %w", url, err))
+	}
+
+	hash, err := w.stripper.Hash(page.Body)
+	if err != nil {
+		return fail(fmt.Errorf("failed to hash page: %s: %w", url, err))
+	}
+
+	event.Hash = hash
+
+	index, err := w.pipeline.history.Index(url)
+	if err != nil {
+		return fail(err)
+	}
+
+	if n := len(index.Snapshots); n > 0 {
+		latest := index.Snapshots[n-1]
+
+		if latest.ContentHash == hash {
+			event.Type = watch.EventUnchanged
+			event.Snapshot = latest.ID
+
+			return event
+		}
+
+		event.PreviousSnapshot = latest.ID
+		event.PreviousHash = latest.ContentHash
+	}
+
+	snap, metadata, err := w.pipeline.savePage(ctx, url, page, hash, assetLog{})
+	if err != nil {
+		return fail(err)
+	}
+
+	if metadata != nil {
+		_, _ = io.WriteString(os.Stderr, w.pipeline.client.StringMetadata(metadata))
+	}
+
+	event.Type = watch.EventChanged
+	event.Snapshot = snap.ID
+
+	return event
+}
+
+// notify prints the event and sends the change and error events to the notifiers.
+func (w *watcher) notify(ctx context.Context, event watch.Event) {
+	if w.events != nil {
+		if err := w.events.Notify(ctx, event); err != nil {
+			_, _ = io.WriteString(os.Stderr, err.Error()+"\n")
+		}
+	}
+
+	if event.Type == watch.EventError {
+		_, _ = io.WriteString(os.Stderr, event.Error+"\n\n")
+	}
+
+	if event.Type == watch.EventUnchanged {
+		return
+	}
+
+	for _, notifier := range w.notifiers {
+		if err := notifier.Notify(ctx, event); err != nil {
+			_, _ = io.WriteString(os.Stderr, "failed to notify: "+err.Error()+"\n")
+		}
+	}
+}
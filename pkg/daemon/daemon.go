@@ -0,0 +1,252 @@
+// Package daemon exposes the job queue over a JSON HTTP API, so other services can request archives.
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/moemoe89/fetch/pkg/jobs"
+	"github.com/moemoe89/fetch/pkg/storage"
+)
+
+// maxRequestBody is the maximum size of a job submission.
+const maxRequestBody = 1 << 20
+
+// jobsRoute is the prefix of the job routes.
+const jobsRoute = "/jobs"
+
+// errInvalidURL represents an error message when a submitted URL isn't an absolute http or https URL.
+var errInvalidURL = errors.New("invalid URL")
+
+// files are the files of a result which can be downloaded, e.g. `/jobs/<id>/results/0/archive`.
+var files = map[string]struct {
+	contentType string
+	name        func(result jobs.Result) string
+}{
+	"page":     {"text/html; charset=utf-8", func(result jobs.Result) string { return result.Page }},
+	"archive":  {"application/zip", func(result jobs.Result) string { return result.Archive }},
+	"metadata": {"application/json", func(result jobs.Result) string { return result.Metadata }},
+}
+
+// SubmitRequest is the body of a job submission.
+type SubmitRequest struct {
+	URLs []string `json:"urls"`
+	jobs.Options
+}
+
+// ErrorResponse is the body of an error response.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler serves the JSON HTTP API of the daemon:
+//
+//	POST /jobs                             submits a job, e.g. {"urls": ["https://example.com"], "metadata": true}
+//	GET  /jobs                             lists the jobs
+//	GET  /jobs/<id>                        returns the status and the results of the job
+//	GET  /jobs/<id>/results/<n>/<file>     downloads the page, archive or metadata of the n-th URL of the job
+//	GET  /healthz                          reports the daemon is up
+type Handler struct {
+	queue   *jobs.Queue
+	storage storage.Storage
+}
+
+// New returns the Handler of the API submitting the jobs to the queue and reading the results from the storage.
+func New(queue *jobs.Queue, s storage.Storage) *Handler {
+	return &Handler{queue: queue, storage: s}
+}
+
+// ServeHTTP routes the request.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/healthz" {
+		h.allow(w, r, http.MethodGet, func() {
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		})
+
+		return
+	}
+
+	if r.URL.Path == jobsRoute {
+		switch r.Method {
+		case http.MethodPost:
+			h.submit(w, r)
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string][]jobs.Job{"jobs": h.queue.List()})
+		default:
+			h.methodNotAllowed(w, http.MethodGet+", "+http.MethodPost)
+		}
+
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, jobsRoute+"/") {
+		writeError(w, http.StatusNotFound, "not found")
+
+		return
+	}
+
+	// <id> or <id>/results/<n>/<file>
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, jobsRoute+"/"), "/")
+
+	h.allow(w, r, http.MethodGet, func() {
+		job, ok := h.queue.Get(parts[0])
+		if !ok {
+			writeError(w, http.StatusNotFound, "job not found")
+
+			return
+		}
+
+		switch {
+		case len(parts) == 1:
+			writeJSON(w, http.StatusOK, job)
+		case len(parts) == 4 && parts[1] == "results":
+			h.download(w, job, parts[2], parts[3])
+		default:
+			writeError(w, http.StatusNotFound, "not found")
+		}
+	})
+}
+
+// submit validates the submission and adds the job to the queue.
+func (h *Handler) submit(w http.ResponseWriter, r *http.Request) {
+	var req SubmitRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode request: %v", err))
+
+		return
+	}
+
+	if len(req.URLs) == 0 {
+		writeError(w, http.StatusBadRequest, "urls is required")
+
+		return
+	}
+
+	for _, rawURL := range req.URLs {
+		if err := validateURL(rawURL); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+
+			return
+		}
+	}
+
+	job, err := h.queue.Submit(req.URLs, req.Options)
+
+	switch {
+	case errors.Is(err, jobs.ErrQueueFull):
+		w.Header().Set("Retry-After", "10")
+		writeError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, jobs.ErrJobTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, jobs.ErrQueueClosed):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	case err != nil:
+		log.Println(err)
+		writeError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	default:
+		w.Header().Set("Location", path.Join(jobsRoute, job.ID))
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// download writes a saved file of the n-th result of the job.
+func (h *Handler) download(w http.ResponseWriter, job jobs.Job, n, file string) {
+	i, err := strconv.Atoi(n)
+	if err != nil || i < 0 || i >= len(job.Results) {
+		writeError(w, http.StatusNotFound, "result not found")
+
+		return
+	}
+
+	f, ok := files[file]
+	if !ok {
+		writeError(w, http.StatusNotFound, "file not found, expected page, archive or metadata")
+
+		return
+	}
+
+	name := f.name(job.Results[i])
+	if name == "" {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s isn't saved for this result", file))
+
+		return
+	}
+
+	body, err := h.storage.ReadFile(name)
+	if errors.Is(err, storage.ErrNotExist) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s doesn't exist anymore", file))
+
+		return
+	}
+
+	if err != nil {
+		log.Println(err)
+		writeError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	w.Header().Set("Content-Type", f.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(name)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+
+	_, _ = w.Write(body)
+}
+
+// allow calls `next` if the request has the given method, it writes 405 otherwise.
+func (h *Handler) allow(w http.ResponseWriter, r *http.Request, method string, next func()) {
+	if r.Method != method {
+		h.methodNotAllowed(w, method)
+
+		return
+	}
+
+	next()
+}
+
+// methodNotAllowed writes 405 with the allowed methods.
+func (h *Handler) methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+}
+
+// validateURL checks the URL is an absolute http or https URL.
+func validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", errInvalidURL, rawURL, err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %s: expected an absolute http or https URL", errInvalidURL, rawURL)
+	}
+
+	return nil
+}
+
+// writeJSON writes the value as the JSON response.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", " ")
+
+	_ = encoder.Encode(value)
+}
+
+// writeError writes the error message as the JSON response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, ErrorResponse{Error: message})
+}
@@ -0,0 +1,221 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/jobs"
+	"github.com/moemoe89/fetch/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	s := storage.NewMemory()
+
+	require.NoError(t, s.WriteFile("https/example.com/_page.zip", []byte("zip")))
+
+	runner := jobs.RunnerFunc(func(ctx context.Context, task jobs.Task) (jobs.Result, error) {
+		return jobs.Result{Page: "https/example.com/_page.html", Archive: "https/example.com/_page.zip"}, nil
+	})
+
+	queue, err := jobs.New(runner, 1, 10)
+	require.NoError(t, err)
+	defer func() { _ = queue.Shutdown(context.Background()) }()
+
+	handler := New(queue, s)
+
+	job, err := queue.Submit([]string{"https://example.com"}, jobs.Options{Metadata: true})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		job, _ = queue.Get(job.ID)
+
+		return job.Status == jobs.StatusDone
+	}, time.Second, time.Millisecond)
+
+	type args struct {
+		method string
+		target string
+		body   string
+	}
+
+	type test struct {
+		args       args
+		wantStatus int
+		wantBody   string
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully submit job": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{method: http.MethodPost, target: "/jobs", body: `{"urls": ["https://example.com"], "metadata": true}`},
+				wantStatus: http.StatusAccepted,
+				wantBody:   `"metadata": true`,
+			}
+		},
+		"Successfully get job": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{method: http.MethodGet, target: "/jobs/" + job.ID},
+				wantStatus: http.StatusOK,
+				wantBody:   `"status": "done"`,
+			}
+		},
+		"Successfully list jobs": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{method: http.MethodGet, target: "/jobs"},
+				wantStatus: http.StatusOK,
+				wantBody:   `"id": "` + job.ID + `"`,
+			}
+		},
+		"Successfully download archive": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{method: http.MethodGet, target: "/jobs/" + job.ID + "/results/0/archive"},
+				wantStatus: http.StatusOK,
+				wantBody:   "zip",
+			}
+		},
+		"Successfully check health": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{method: http.MethodGet, target: "/healthz"},
+				wantStatus: http.StatusOK,
+				wantBody:   `"status": "ok"`,
+			}
+		},
+		"Failed submit invalid URL": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{method: http.MethodPost, target: "/jobs", body: `{"urls": ["example.com"]}`},
+				wantStatus: http.StatusBadRequest,
+				wantBody:   "invalid URL",
+			}
+		},
+		"Failed submit without URL": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{method: http.MethodPost, target: "/jobs", body: `{"urls": []}`},
+				wantStatus: http.StatusBadRequest,
+				wantBody:   "urls is required",
+			}
+		},
+		"Failed submit unknown field": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{method: http.MethodPost, target: "/jobs", body: `{"url": "https://example.com"}`},
+				wantStatus: http.StatusBadRequest,
+				wantBody:   "unknown field",
+			}
+		},
+		"Failed get missing job": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{method: http.MethodGet, target: "/jobs/missing"},
+				wantStatus: http.StatusNotFound,
+				wantBody:   "job not found",
+			}
+		},
+		"Failed download missing file": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{method: http.MethodGet, target: "/jobs/" + job.ID + "/results/0/page"},
+				wantStatus: http.StatusNotFound,
+				wantBody:   "doesn't exist",
+			}
+		},
+		"Failed download unknown result": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{method: http.MethodGet, target: "/jobs/" + job.ID + "/results/1/archive"},
+				wantStatus: http.StatusNotFound,
+				wantBody:   "result not found",
+			}
+		},
+		"Failed method not allowed": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{method: http.MethodDelete, target: "/jobs/" + job.ID},
+				wantStatus: http.StatusMethodNotAllowed,
+				wantBody:   "Method Not Allowed",
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			req := httptest.NewRequest(tt.args.method, tt.args.target, strings.NewReader(tt.args.body))
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+
+			if rec.Header().Get("Content-Type") == "application/json" {
+				assert.True(t, json.Valid(rec.Body.Bytes()))
+			}
+		})
+	}
+}
+
+func TestHandlerQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	runner := jobs.RunnerFunc(func(ctx context.Context, task jobs.Task) (jobs.Result, error) {
+		<-release
+
+		return jobs.Result{}, nil
+	})
+
+	queue, err := jobs.New(runner, 1, 2)
+	require.NoError(t, err)
+
+	handler := New(queue, storage.NewMemory())
+
+	body := `{"urls": ["https://a.com", "https://b.com"]}`
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body)))
+
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	// At most one URL is taken by the worker, so the queue can't take two more.
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+
+	// A job with more URLs than the queue size is rejected without Retry-After, it would never be taken.
+	body = `{"urls": ["https://a.com", "https://b.com", "https://c.com"]}`
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+}
@@ -0,0 +1,319 @@
+// Package diff compares two saved versions of a page:
+// the visible text line by line and the metadata structure (title, links and assets).
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/moemoe89/fetch/pkg/fetcher"
+)
+
+// contextLines is the number of unchanged lines printed around the changes by WriteText.
+const contextLines = 3
+
+// Version is a saved version of a page, e.g. a snapshot.
+type Version struct {
+	// Label names the version in the reports, e.g. the snapshot ID.
+	Label    string
+	HTML     []byte
+	Metadata *fetcher.Metadata
+}
+
+// Change is a value changed between the versions.
+type Change struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// AssetChange is an asset referenced by both versions whose content is different.
+type AssetChange struct {
+	URL        string `json:"url"`
+	FromDigest string `json:"from_digest"`
+	ToDigest   string `json:"to_digest"`
+}
+
+// Result is the difference between two versions of a page.
+type Result struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Text is the line diff of the visible text, including the unchanged lines.
+	Text []Line `json:"text"`
+	// Title is nil if the title didn't change.
+	Title         *Change       `json:"title,omitempty"`
+	LinksAdded    []string      `json:"links_added,omitempty"`
+	LinksRemoved  []string      `json:"links_removed,omitempty"`
+	AssetsAdded   []string      `json:"assets_added,omitempty"`
+	AssetsRemoved []string      `json:"assets_removed,omitempty"`
+	AssetsChanged []AssetChange `json:"assets_changed,omitempty"`
+}
+
+// Compare compares the `from` version of the page to the `to` version.
+func Compare(from, to Version) (*Result, error) {
+	fromText, err := VisibleText(bytes.NewReader(from.HTML))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read text of %s: %w", from.Label, err)
+	}
+
+	toText, err := VisibleText(bytes.NewReader(to.HTML))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read text of %s: %w", to.Label, err)
+	}
+
+	result := &Result{
+		From: from.Label,
+		To:   to.Label,
+		Text: Lines(fromText, toText),
+	}
+
+	fromMetadata, toMetadata := from.Metadata, to.Metadata
+	if fromMetadata == nil {
+		fromMetadata = &fetcher.Metadata{}
+	}
+
+	if toMetadata == nil {
+		toMetadata = &fetcher.Metadata{}
+	}
+
+	if fromMetadata.Title != toMetadata.Title {
+		result.Title = &Change{From: fromMetadata.Title, To: toMetadata.Title}
+	}
+
+	result.LinksAdded, result.LinksRemoved = compareSets(fromMetadata.Links, toMetadata.Links)
+
+	fromAssets, toAssets := assets(fromMetadata), assets(toMetadata)
+
+	result.AssetsAdded, result.AssetsRemoved = compareSets(keys(fromAssets), keys(toAssets))
+
+	for _, url := range keys(toAssets) {
+		fromDigest, ok := fromAssets[url]
+		if !ok || fromDigest == "" || toAssets[url] == "" || fromDigest == toAssets[url] {
+			continue
+		}
+
+		result.AssetsChanged = append(result.AssetsChanged, AssetChange{URL: url, FromDigest: fromDigest, ToDigest: toAssets[url]})
+	}
+
+	return result, nil
+}
+
+// Changed reports whether anything is different between the versions.
+func (r *Result) Changed() bool {
+	return r.TextChanged() || r.Title != nil ||
+		len(r.LinksAdded) > 0 || len(r.LinksRemoved) > 0 ||
+		len(r.AssetsAdded) > 0 || len(r.AssetsRemoved) > 0 || len(r.AssetsChanged) > 0
+}
+
+// TextChanged reports whether the visible text is different between the versions.
+func (r *Result) TextChanged() bool {
+	for _, line := range r.Text {
+		if line.Op != OpEqual {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WriteText writes the result as plain text: the metadata changes followed by the text diff
+// in the unified format, with a few unchanged lines around every change.
+func WriteText(w io.Writer, r *Result) error {
+	ew := &errWriter{w: w}
+
+	ew.printf("--- %s\n+++ %s\n", r.From, r.To)
+
+	if !r.Changed() {
+		ew.printf("no changes\n")
+
+		return ew.err
+	}
+
+	if r.Title != nil {
+		ew.printf("title: %q -> %q\n", r.Title.From, r.Title.To)
+	}
+
+	for _, link := range r.LinksAdded {
+		ew.printf("link added: %s\n", link)
+	}
+
+	for _, link := range r.LinksRemoved {
+		ew.printf("link removed: %s\n", link)
+	}
+
+	for _, asset := range r.AssetsAdded {
+		ew.printf("asset added: %s\n", asset)
+	}
+
+	for _, asset := range r.AssetsRemoved {
+		ew.printf("asset removed: %s\n", asset)
+	}
+
+	for _, asset := range r.AssetsChanged {
+		ew.printf("asset changed: %s (%s -> %s)\n", asset.URL, shortDigest(asset.FromDigest), shortDigest(asset.ToDigest))
+	}
+
+	for _, hunk := range hunks(r.Text) {
+		ew.printf("@@ -%d,%d +%d,%d @@\n", hunk.fromLine, hunk.fromCount, hunk.toLine, hunk.toCount)
+
+		for _, line := range hunk.lines {
+			switch line.Op {
+			case OpDelete:
+				ew.printf("-%s\n", line.Text)
+			case OpInsert:
+				ew.printf("+%s\n", line.Text)
+			default:
+				ew.printf(" %s\n", line.Text)
+			}
+		}
+	}
+
+	return ew.err
+}
+
+// hunk is a group of changed lines with their context, numbered from 1 as in the unified format.
+type hunk struct {
+	fromLine, fromCount int
+	toLine, toCount     int
+	lines               []Line
+}
+
+// hunks groups the changes of the diff which are less than 2*contextLines apart.
+func hunks(lines []Line) []hunk {
+	var result []hunk
+
+	// Line numbers of the versions before lines[i].
+	fromLines := make([]int, len(lines)+1)
+	toLines := make([]int, len(lines)+1)
+
+	for i, line := range lines {
+		fromLines[i+1], toLines[i+1] = fromLines[i], toLines[i]
+
+		if line.Op != OpInsert {
+			fromLines[i+1]++
+		}
+
+		if line.Op != OpDelete {
+			toLines[i+1]++
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == OpEqual {
+			i++
+
+			continue
+		}
+
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+
+		// Extends the hunk while the next change is close enough.
+		end := i
+		for j := i; j < len(lines) && j <= end+2*contextLines; j++ {
+			if lines[j].Op != OpEqual {
+				end = j
+			}
+		}
+
+		stop := end + 1 + contextLines
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+
+		result = append(result, hunk{
+			fromLine:  fromLines[start] + 1,
+			fromCount: fromLines[stop] - fromLines[start],
+			toLine:    toLines[start] + 1,
+			toCount:   toLines[stop] - toLines[start],
+			lines:     lines[start:stop],
+		})
+
+		i = stop
+	}
+
+	return result
+}
+
+// assets maps the assets of the page to their digest, empty if the digest isn't recorded.
+func assets(metadata *fetcher.Metadata) map[string]string {
+	result := make(map[string]string, len(metadata.Assets))
+
+	for _, asset := range metadata.Assets {
+		result[asset] = ""
+	}
+
+	for asset, digest := range metadata.AssetDigests {
+		result[asset] = digest
+	}
+
+	return result
+}
+
+// compareSets returns the sorted values only in `to` and the sorted values only in `from`.
+func compareSets(from, to []string) (added, removed []string) {
+	fromSet := make(map[string]bool, len(from))
+	for _, value := range from {
+		fromSet[value] = true
+	}
+
+	toSet := make(map[string]bool, len(to))
+	for _, value := range to {
+		toSet[value] = true
+	}
+
+	for value := range toSet {
+		if !fromSet[value] {
+			added = append(added, value)
+		}
+	}
+
+	for value := range fromSet {
+		if !toSet[value] {
+			removed = append(removed, value)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	return added, removed
+}
+
+// keys returns the sorted keys of the map.
+func keys(m map[string]string) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// shortDigest shortens the digest for display.
+func shortDigest(digest string) string {
+	if len(digest) > 12 {
+		return digest[:12]
+	}
+
+	return digest
+}
+
+// errWriter keeps the first write error, so the writes don't need to be checked one by one.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
@@ -0,0 +1,154 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/moemoe89/fetch/pkg/fetcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisibleText(t *testing.T) {
+	page := `<html><head><title>Title</title><style>p{}</style></head>
+<body><h1>Hello,
+   world</h1><script>var a = 1;</script><p>First <b>bold</b></p><noscript>enable js</noscript></body></html>`
+
+	got, err := VisibleText(strings.NewReader(page))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Hello, world", "First", "bold"}, got)
+}
+
+func TestLines(t *testing.T) {
+	type args struct {
+		a, b []string
+	}
+
+	type test struct {
+		args args
+		want []Line
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Equal lines": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{a: []string{"a", "b"}, b: []string{"a", "b"}},
+				want: []Line{{OpEqual, "a"}, {OpEqual, "b"}},
+			}
+		},
+		"Replaced line in the middle": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{a: []string{"a", "b", "c"}, b: []string{"a", "x", "c"}},
+				want: []Line{{OpEqual, "a"}, {OpDelete, "b"}, {OpInsert, "x"}, {OpEqual, "c"}},
+			}
+		},
+		"Inserted and deleted lines": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{a: []string{"a", "b", "c", "a", "b", "b", "a"}, b: []string{"c", "b", "a", "b", "a", "c"}},
+				want: []Line{
+					{OpDelete, "a"}, {OpDelete, "b"}, {OpEqual, "c"}, {OpInsert, "b"}, {OpEqual, "a"},
+					{OpEqual, "b"}, {OpDelete, "b"}, {OpEqual, "a"}, {OpInsert, "c"},
+				},
+			}
+		},
+		"Empty old text": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{b: []string{"a"}},
+				want: []Line{{OpInsert, "a"}},
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			got := Lines(tt.args.a, tt.args.b)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLinesApply(t *testing.T) {
+	a := strings.Split("the quick brown fox jumps over the lazy dog and runs away", " ")
+	b := strings.Split("a quick red fox jumps over the dog and then runs far away", " ")
+
+	var gotA, gotB []string
+
+	for _, line := range Lines(a, b) {
+		if line.Op != OpInsert {
+			gotA = append(gotA, line.Text)
+		}
+
+		if line.Op != OpDelete {
+			gotB = append(gotB, line.Text)
+		}
+	}
+
+	assert.Equal(t, a, gotA)
+	assert.Equal(t, b, gotB)
+}
+
+func TestCompare(t *testing.T) {
+	from := Version{
+		Label: "20261001T100000Z",
+		HTML:  []byte(`<html><body><p>Hello</p><p>Old text</p><a href="/a">a</a></body></html>`),
+		Metadata: &fetcher.Metadata{
+			Title:        "Old",
+			Links:        []string{"/a", "/b"},
+			Assets:       []string{"/style.css", "/old.js"},
+			AssetDigests: map[string]string{"/style.css": "aaaa", "/old.js": "bbbb"},
+		},
+	}
+
+	to := Version{
+		Label: "20261002T100000Z",
+		HTML:  []byte(`<html><body><p>Hello</p><p>New text</p><a href="/a">a</a></body></html>`),
+		Metadata: &fetcher.Metadata{
+			Title:        "New",
+			Links:        []string{"/a", "/c"},
+			Assets:       []string{"/style.css", "/new.js"},
+			AssetDigests: map[string]string{"/style.css": "cccc", "/new.js": "dddd"},
+		},
+	}
+
+	result, err := Compare(from, to)
+	require.NoError(t, err)
+
+	assert.True(t, result.Changed())
+	assert.Equal(t, &Change{From: "Old", To: "New"}, result.Title)
+	assert.Equal(t, []string{"/c"}, result.LinksAdded)
+	assert.Equal(t, []string{"/b"}, result.LinksRemoved)
+	assert.Equal(t, []string{"/new.js"}, result.AssetsAdded)
+	assert.Equal(t, []string{"/old.js"}, result.AssetsRemoved)
+	assert.Equal(t, []AssetChange{{URL: "/style.css", FromDigest: "aaaa", ToDigest: "cccc"}}, result.AssetsChanged)
+	assert.Equal(t, []Line{{OpEqual, "Hello"}, {OpDelete, "Old text"}, {OpInsert, "New text"}, {OpEqual, "a"}}, result.Text)
+
+	var text bytes.Buffer
+	require.NoError(t, WriteText(&text, result))
+
+	assert.Contains(t, text.String(), "title: \"Old\" -> \"New\"\n")
+	assert.Contains(t, text.String(), "@@ -1,3 +1,3 @@\n Hello\n-Old text\n+New text\n a\n")
+
+	var report bytes.Buffer
+	require.NoError(t, WriteReport(&report, result))
+
+	assert.Contains(t, report.String(), `<td class="delete">Old text</td>`)
+	assert.Contains(t, report.String(), `<td class="insert">New text</td>`)
+
+	same, err := Compare(from, from)
+	require.NoError(t, err)
+
+	assert.False(t, same.Changed())
+}
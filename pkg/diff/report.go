@@ -0,0 +1,152 @@
+package diff
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+)
+
+// row is a line of the side-by-side report, a side is empty if the line only exists in the other version.
+type row struct {
+	FromNum, ToNum int
+	From, To       string
+	FromOp, ToOp   Op
+}
+
+// reportTemplate renders the side-by-side HTML report.
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Result.From}} .. {{.Result.To}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; table-layout: fixed; }
+td, th { border: 1px solid #ddd; padding: 2px 6px; vertical-align: top; text-align: left; word-wrap: break-word; }
+td.num { width: 3em; color: #888; text-align: right; }
+.delete { background: #fdd; }
+.insert { background: #dfd; }
+code { font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>{{.Result.From}} &rarr; {{.Result.To}}</h1>
+{{- if not .Result.Changed}}
+<p>No changes.</p>
+{{- end}}
+{{- with .Result.Title}}
+<h2>Title</h2>
+<table><tr><td class="delete">{{.From}}</td><td class="insert">{{.To}}</td></tr></table>
+{{- end}}
+{{- if or .Result.LinksAdded .Result.LinksRemoved}}
+<h2>Links</h2>
+<ul>
+{{- range .Result.LinksRemoved}}
+<li class="delete">removed <code>{{.}}</code></li>
+{{- end}}
+{{- range .Result.LinksAdded}}
+<li class="insert">added <code>{{.}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if or .Result.AssetsAdded .Result.AssetsRemoved .Result.AssetsChanged}}
+<h2>Assets</h2>
+<ul>
+{{- range .Result.AssetsRemoved}}
+<li class="delete">removed <code>{{.}}</code></li>
+{{- end}}
+{{- range .Result.AssetsAdded}}
+<li class="insert">added <code>{{.}}</code></li>
+{{- end}}
+{{- range .Result.AssetsChanged}}
+<li>changed <code>{{.URL}}</code>: <code>{{.FromDigest}}</code> &rarr; <code>{{.ToDigest}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+<h2>Text</h2>
+<table>
+<tr><th class="num"></th><th>{{.Result.From}}</th><th class="num"></th><th>{{.Result.To}}</th></tr>
+{{- range .Rows}}
+<tr>
+<td class="num">{{if .FromNum}}{{.FromNum}}{{end}}</td><td class="{{.FromOp}}">{{.From}}</td>
+<td class="num">{{if .ToNum}}{{.ToNum}}{{end}}</td><td class="{{.ToOp}}">{{.To}}</td>
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// WriteReport writes the result as a standalone HTML page showing both versions side by side.
+func WriteReport(w io.Writer, r *Result) error {
+	data := struct {
+		Result *Result
+		Rows   []row
+	}{
+		Result: r,
+		Rows:   rows(r.Text),
+	}
+
+	if err := reportTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	return nil
+}
+
+// rows pairs the deleted lines with the inserted lines following them, so replaced lines are on the same row.
+func rows(lines []Line) []row {
+	var (
+		result         []row
+		fromNum, toNum int
+		deleted        []string
+		inserted       []string
+	)
+
+	flush := func() {
+		for i := 0; i < len(deleted) || i < len(inserted); i++ {
+			var r row
+
+			if i < len(deleted) {
+				fromNum++
+				r.FromNum, r.From, r.FromOp = fromNum, deleted[i], OpDelete
+			}
+
+			if i < len(inserted) {
+				toNum++
+				r.ToNum, r.To, r.ToOp = toNum, inserted[i], OpInsert
+			}
+
+			result = append(result, r)
+		}
+
+		deleted, inserted = nil, nil
+	}
+
+	for _, line := range lines {
+		switch line.Op {
+		case OpDelete:
+			if len(inserted) > 0 {
+				flush()
+			}
+
+			deleted = append(deleted, line.Text)
+		case OpInsert:
+			inserted = append(inserted, line.Text)
+		default:
+			flush()
+
+			fromNum++
+			toNum++
+
+			result = append(result, row{
+				FromNum: fromNum, From: line.Text, FromOp: OpEqual,
+				ToNum: toNum, To: line.Text, ToOp: OpEqual,
+			})
+		}
+	}
+
+	flush()
+
+	return result
+}
@@ -0,0 +1,233 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxEdits is the maximum number of edits searched by the line diff,
+// larger differences are reported as the whole old text removed and the whole new text added.
+const maxEdits = 2000
+
+// Op is the operation of a diff line.
+type Op string
+
+// Diff line operations.
+const (
+	OpEqual  Op = "equal"
+	OpDelete Op = "delete"
+	OpInsert Op = "insert"
+)
+
+// Line is a line of the text diff.
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// hiddenElements are the elements whose text isn't visible on the page.
+var hiddenElements = map[string]bool{
+	"head":     true,
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"svg":      true,
+}
+
+// VisibleText parses the HTML document and returns its visible text, one line per text node
+// with the whitespaces collapsed. Scripts, styles and the head are skipped.
+func VisibleText(file io.Reader) ([]string, error) {
+	doc, err := html.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	return visibleText(doc), nil
+}
+
+// visibleText returns the visible text lines of the node.
+func visibleText(doc *html.Node) []string {
+	var lines []string
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && hiddenElements[n.Data] {
+			return
+		}
+
+		if n.Type == html.TextNode {
+			if line := strings.Join(strings.Fields(n.Data), " "); line != "" {
+				lines = append(lines, line)
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	return lines
+}
+
+// Lines computes the line diff from `a` to `b` with the Myers algorithm.
+func Lines(a, b []string) []Line {
+	// Common prefix and suffix are equal lines, they don't need to be searched.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(a)+len(b))
+
+	for _, text := range a[:prefix] {
+		lines = append(lines, Line{Op: OpEqual, Text: text})
+	}
+
+	lines = append(lines, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, Line{Op: OpEqual, Text: text})
+	}
+
+	return lines
+}
+
+// myers finds the shortest edit script from `a` to `b`.
+// The trace of every step keeps only the reachable diagonals, so the memory is O(D^2).
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+
+	if n == 0 || m == 0 {
+		return replaceAll(a, b)
+	}
+
+	// v[k+d] of the step d is the furthest x reached on the diagonal k.
+	var trace [][]int
+
+	v := []int{0, 0}
+
+	for d := 0; d <= n+m && d <= maxEdits; d++ {
+		next := make([]int, 2*d+3)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+
+			if k == -d || (k != d && get(v, k-1, d-1) < get(v, k+1, d-1)) {
+				x = get(v, k+1, d-1)
+			} else {
+				x = get(v, k-1, d-1) + 1
+			}
+
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			next[k+d+1] = x
+
+			if x >= n && y >= m {
+				trace = append(trace, next)
+
+				return backtrack(trace, a, b)
+			}
+		}
+
+		trace = append(trace, next)
+		v = next
+	}
+
+	return replaceAll(a, b)
+}
+
+// get returns the furthest x of the diagonal k from the trace step d, the step -1 is the starting point.
+func get(v []int, k, d int) int {
+	if d < 0 {
+		return 0
+	}
+
+	i := k + d + 1
+	if i < 0 || i >= len(v) {
+		return -1
+	}
+
+	return v[i]
+}
+
+// backtrack walks the trace from the end to build the edit script.
+func backtrack(trace [][]int, a, b []string) []Line {
+	x, y := len(a), len(b)
+
+	var lines []Line
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		k := x - y
+
+		var prevK int
+
+		if d == 0 {
+			prevK = 0
+		} else if k == -d || (k != d && get(trace[d-1], k-1, d-1) < get(trace[d-1], k+1, d-1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := 0
+		if d > 0 {
+			prevX = get(trace[d-1], prevK, d-1)
+		}
+
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			lines = append(lines, Line{Op: OpEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+
+		if d == 0 {
+			break
+		}
+
+		if x == prevX {
+			lines = append(lines, Line{Op: OpInsert, Text: b[y-1]})
+		} else {
+			lines = append(lines, Line{Op: OpDelete, Text: a[x-1]})
+		}
+
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+
+	return lines
+}
+
+// replaceAll returns the diff deleting all lines of `a` and inserting all lines of `b`.
+func replaceAll(a, b []string) []Line {
+	lines := make([]Line, 0, len(a)+len(b))
+
+	for _, text := range a {
+		lines = append(lines, Line{Op: OpDelete, Text: text})
+	}
+
+	for _, text := range b {
+		lines = append(lines, Line{Op: OpInsert, Text: text})
+	}
+
+	return lines
+}
@@ -0,0 +1,336 @@
+package fetcher
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	// errMissingMetadata represents an error message when the archive doesn't contain the metadata JSON with checksums.
+	errMissingMetadata = errors.New("archive has no metadata checksums")
+	// errChecksumMismatch represents an error message when an archive entry doesn't match the recorded checksum.
+	errChecksumMismatch = errors.New("checksum mismatch")
+	// errUnsupportedEntry represents an error message when an archive entry is neither a regular file nor a directory.
+	errUnsupportedEntry = errors.New("unsupported zip entry")
+)
+
+// Entry status after verifying the archive against the metadata checksums.
+const (
+	EntryStatusOK         = "ok"
+	EntryStatusMismatch   = "mismatch"
+	EntryStatusUnrecorded = "unrecorded"
+	EntryStatusMissing    = "missing"
+)
+
+// Archive is a zip archive produced by Zip opened for reading.
+type Archive struct {
+	reader *zip.Reader
+	closer io.Closer
+	// Metadata is the metadata JSON stored in the archive, nil if the archive doesn't have it.
+	Metadata *Metadata
+	// MetadataName is the entry name of the metadata JSON.
+	MetadataName string
+}
+
+// ArchiveEntry describes a file stored in the archive.
+type ArchiveEntry struct {
+	Name             string
+	Size             uint64
+	CompressedSize   uint64
+	Checksum         string
+	RecordedChecksum string
+	Status           string
+}
+
+// OpenArchive opens the zip archive specified by `filename` and rejects unsafe entry names.
+func OpenArchive(filename string) (*Archive, error) {
+	readCloser, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip: %w", err)
+	}
+
+	archive, err := newArchive(&readCloser.Reader)
+	if err != nil {
+		_ = readCloser.Close()
+
+		return nil, err
+	}
+
+	archive.closer = readCloser
+
+	return archive, nil
+}
+
+// NewArchive reads the zip archive of the given `size` from `readerAt` and rejects unsafe entry names.
+func NewArchive(readerAt io.ReaderAt, size int64) (*Archive, error) {
+	reader, err := zip.NewReader(readerAt, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip: %w", err)
+	}
+
+	return newArchive(reader)
+}
+
+// newArchive verifies the entries and loads the metadata JSON of the archive.
+func newArchive(reader *zip.Reader) (*Archive, error) {
+	if err := VerifyArchive(reader); err != nil {
+		return nil, err
+	}
+
+	archive := &Archive{reader: reader}
+
+	for _, file := range reader.File {
+		if !isMetadataEntry(file.Name) {
+			continue
+		}
+
+		body, err := readEntry(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var metadata *Metadata
+
+		if err := json.Unmarshal(body, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %s: %w", file.Name, err)
+		}
+
+		archive.Metadata = metadata
+		archive.MetadataName = file.Name
+
+		break
+	}
+
+	return archive, nil
+}
+
+// Close closes the underlying archive file if it's opened by OpenArchive.
+func (a *Archive) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+
+	return a.closer.Close()
+}
+
+// Open opens the archive entry with the given name, e.g. to serve it without extracting the archive.
+func (a *Archive) Open(name string) (fs.File, error) {
+	file, err := a.reader.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open entry: %s: %w", name, err)
+	}
+
+	return file, nil
+}
+
+// Entries lists the files stored in the archive sorted by name,
+// followed by the recorded files missing from the archive.
+// The checksum of every file is calculated and compared to the one recorded in the metadata.
+func (a *Archive) Entries() ([]ArchiveEntry, error) {
+	var recorded map[string]string
+	if a.Metadata != nil {
+		recorded = a.Metadata.Checksums
+	}
+
+	entries := make([]ArchiveEntry, 0, len(a.reader.File))
+	found := make(map[string]bool, len(a.reader.File))
+
+	for _, file := range a.reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		checksum, err := entryChecksum(file)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := ArchiveEntry{
+			Name:             file.Name,
+			Size:             file.UncompressedSize64,
+			CompressedSize:   file.CompressedSize64,
+			Checksum:         checksum,
+			RecordedChecksum: recorded[file.Name],
+			Status:           EntryStatusUnrecorded,
+		}
+
+		if entry.RecordedChecksum != "" {
+			entry.Status = EntryStatusOK
+
+			if entry.RecordedChecksum != entry.Checksum {
+				entry.Status = EntryStatusMismatch
+			}
+		}
+
+		found[file.Name] = true
+
+		entries = append(entries, entry)
+	}
+
+	for name, checksum := range recorded {
+		if !found[name] {
+			entries = append(entries, ArchiveEntry{Name: name, RecordedChecksum: checksum, Status: EntryStatusMissing})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if (entries[i].Status == EntryStatusMissing) != (entries[j].Status == EntryStatusMissing) {
+			return entries[j].Status == EntryStatusMissing
+		}
+
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
+
+// Verify checks every checksum recorded in the metadata against the archive entries.
+// Entries without a recorded checksum, e.g. the metadata JSON itself, are not verified.
+func (a *Archive) Verify() error {
+	if a.Metadata == nil || len(a.Metadata.Checksums) == 0 {
+		return errMissingMetadata
+	}
+
+	entries, err := a.Entries()
+	if err != nil {
+		return err
+	}
+
+	var invalid []string
+
+	for _, entry := range entries {
+		if entry.Status == EntryStatusMismatch || entry.Status == EntryStatusMissing {
+			invalid = append(invalid, entry.Name+" ("+entry.Status+")")
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("%w: %s", errChecksumMismatch, strings.Join(invalid, ", "))
+	}
+
+	return nil
+}
+
+// Extract writes every archive entry into the `dir` directory.
+// Entry names are validated again and only regular files and directories are extracted,
+// so nothing can be written outside of `dir`.
+func (a *Archive) Extract(dir string) error {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed get absolute path: %s: %w", dir, err)
+	}
+
+	for _, file := range a.reader.File {
+		name := strings.TrimSuffix(file.Name, "/")
+
+		if err := ValidateEntryName(name); err != nil {
+			return err
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(name))
+
+		if !strings.HasPrefix(target, root+string(filepath.Separator)) {
+			return fmt.Errorf("%w: %s: escapes archive root", errUnsafeEntryPath, file.Name)
+		}
+
+		mode := file.Mode()
+
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create dir: %s: %w", target, err)
+			}
+		case mode.IsRegular():
+			if err := extractFile(file, target); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: %s: %s", errUnsupportedEntry, file.Name, mode)
+		}
+	}
+
+	return nil
+}
+
+// extractFile writes the content of the archive entry into the target file.
+func extractFile(file *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create dir: %s: %w", filepath.Dir(target), err)
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open entry: %s: %w", file.Name, err)
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	output, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %s: %w", target, err)
+	}
+
+	if _, err := io.Copy(output, reader); err != nil {
+		_ = output.Close()
+
+		return fmt.Errorf("failed to extract entry: %s: %w", file.Name, err)
+	}
+
+	if err := output.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %s: %w", target, err)
+	}
+
+	return nil
+}
+
+// isMetadataEntry reports whether the entry is the metadata JSON of the page,
+// e.g. `https/example.com/_metadata.json`.
+func isMetadataEntry(name string) bool {
+	return path.Base(name) == MetadataFilename
+}
+
+// readEntry reads the whole content of the archive entry.
+func readEntry(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open entry: %s: %w", file.Name, err)
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entry: %s: %w", file.Name, err)
+	}
+
+	return body, nil
+}
+
+// entryChecksum calculates the SHA-256 hex digest of the archive entry content.
+func entryChecksum(file *zip.File) (string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open entry: %s: %w", file.Name, err)
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	hash := sha256.New()
+
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", fmt.Errorf("failed to read entry: %s: %w", file.Name, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,170 @@
+package fetcher
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestArchive writes a zip archive with the given entries and returns its content.
+func writeTestArchive(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	zipWriter := zip.NewWriter(&buf)
+
+	for name, body := range entries {
+		writer, err := zipWriter.Create(name)
+		require.NoError(t, err)
+
+		_, err = writer.Write(body)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, zipWriter.Close())
+
+	return buf.Bytes()
+}
+
+// testMetadataJSON returns the metadata JSON with checksums of the given entries.
+func testMetadataJSON(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+
+	metadata := &Metadata{Site: "https://example.com", Checksums: make(map[string]string)}
+
+	for name, body := range entries {
+		metadata.Checksums[name] = Checksum(body)
+	}
+
+	body, err := json.Marshal(metadata)
+	require.NoError(t, err)
+
+	return body
+}
+
+func TestArchive(t *testing.T) {
+	page := []byte("<html></html>")
+	style := []byte("body{}")
+
+	recorded := map[string][]byte{
+		"example.com.html":      page,
+		"example.com/style.css": style,
+	}
+
+	type test struct {
+		entries       map[string][]byte
+		wantOpenErr   error
+		wantVerifyErr error
+		wantStatus    map[string]string
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully verify and extract archive": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				entries: map[string][]byte{
+					"example.com.html":           page,
+					"example.com/style.css":      style,
+					"example.com/_metadata.json": testMetadataJSON(t, recorded),
+				},
+				wantStatus: map[string]string{
+					"example.com.html":           EntryStatusOK,
+					"example.com/style.css":      EntryStatusOK,
+					"example.com/_metadata.json": EntryStatusUnrecorded,
+				},
+			}
+		},
+		"Failed verify tampered and missing entries": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				entries: map[string][]byte{
+					"example.com.html":           []byte("<html>tampered</html>"),
+					"example.com/_metadata.json": testMetadataJSON(t, recorded),
+				},
+				wantVerifyErr: errChecksumMismatch,
+				wantStatus: map[string]string{
+					"example.com.html":           EntryStatusMismatch,
+					"example.com/style.css":      EntryStatusMissing,
+					"example.com/_metadata.json": EntryStatusUnrecorded,
+				},
+			}
+		},
+		"Failed verify archive without metadata": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				entries: map[string][]byte{
+					"example.com.html": page,
+				},
+				wantVerifyErr: errMissingMetadata,
+				wantStatus: map[string]string{
+					"example.com.html": EntryStatusUnrecorded,
+				},
+			}
+		},
+		"Failed open zip-slip archive": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				entries: map[string][]byte{
+					"../../evil.sh": []byte("rm -rf /"),
+				},
+				wantOpenErr: errUnsafeEntryPath,
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			body := writeTestArchive(t, tt.entries)
+
+			archive, err := NewArchive(bytes.NewReader(body), int64(len(body)))
+			if tt.wantOpenErr != nil {
+				assert.ErrorIs(t, err, tt.wantOpenErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			entries, err := archive.Entries()
+			require.NoError(t, err)
+
+			got := make(map[string]string, len(entries))
+			for _, entry := range entries {
+				got[entry.Name] = entry.Status
+			}
+
+			assert.Equal(t, tt.wantStatus, got)
+
+			err = archive.Verify()
+			if tt.wantVerifyErr != nil {
+				assert.ErrorIs(t, err, tt.wantVerifyErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			dir := t.TempDir()
+
+			require.NoError(t, archive.Extract(dir))
+
+			extracted, err := os.ReadFile(filepath.Join(dir, "example.com", "style.css"))
+			require.NoError(t, err)
+
+			assert.Equal(t, style, extracted)
+		})
+	}
+}
@@ -9,12 +9,65 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"sync"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/storage"
 )
 
 // errFailedSetHTTPClient represents an error message when the process of setting the HTTP client fails.
 var errFailedSetHTTPClient = errors.New("failed to set client.http_client")
 
+// errFailedSetStorage represents an error message when the process of setting the storage fails.
+var errFailedSetStorage = errors.New("failed to set client.storage")
+
+// errFailedSetZipConcurrency represents an error message when the process of setting the zip concurrency fails.
+var errFailedSetZipConcurrency = errors.New("failed to set client.zip_concurrency")
+
+// errFailedSetHeaders represents an error message when the process of setting the request headers fails.
+var errFailedSetHeaders = errors.New("failed to set client.headers")
+
+// errFailedSetTimeout represents an error message when the process of setting the request timeout fails.
+var errFailedSetTimeout = errors.New("failed to set client.timeout")
+
+// errFailedSetConnectTimeout represents an error message when the process of setting the connect timeout fails.
+var errFailedSetConnectTimeout = errors.New("failed to set client.connect_timeout")
+
+// errFailedSetIdleTimeout represents an error message when the process of setting the idle timeout fails.
+var errFailedSetIdleTimeout = errors.New("failed to set client.idle_timeout")
+
+// errFailedSetMaxBodySize represents an error message when the process of setting the max body size fails.
+var errFailedSetMaxBodySize = errors.New("failed to set client.max_body_size")
+
+// errFailedSetContentTypes represents an error message when the process of setting the content types fails.
+var errFailedSetContentTypes = errors.New("failed to set client.content_types")
+
+// errFailedSetDenyPrivateNetworks represents an error message when the process of setting the denied private networks fails.
+var errFailedSetDenyPrivateNetworks = errors.New("failed to set client.deny_private_networks")
+
+// errFailedSetProxy represents an error message when the process of setting the proxy fails.
+var errFailedSetProxy = errors.New("failed to set client.proxy")
+
+// errFailedSetCACertificates represents an error message when the process of setting the CA certificates fails.
+var errFailedSetCACertificates = errors.New("failed to set client.ca_certificates")
+
+// errFailedSetClientCertificate represents an error message when the process of setting the client certificate fails.
+var errFailedSetClientCertificate = errors.New("failed to set client.client_certificate")
+
+// errFailedSetMinTLSVersion represents an error message when the process of setting the minimum TLS version fails.
+var errFailedSetMinTLSVersion = errors.New("failed to set client.min_tls_version")
+
+// errFailedSetCertificatePins represents an error message when the process of setting the pinned public keys fails.
+var errFailedSetCertificatePins = errors.New("failed to set client.certificate_pins")
+
+// errFailedSetMaxRedirects represents an error message when the process of setting the max redirects fails.
+var errFailedSetMaxRedirects = errors.New("failed to set client.max_redirects")
+
+// errTransportHTTPClient represents an error message when the connection or redirect options are set with a custom HTTP client.
+var errTransportHTTPClient = errors.New("connection and redirect options can't be used with a custom HTTP client")
+
+// errFailedSetResponseCheck represents an error message when the process of setting the response check fails.
+var errFailedSetResponseCheck = errors.New("failed to set client.response_check")
+
 // Fetcher is an interface that defines the methods for fetching a page from a website
 // and saving it to disk, as well as extracting metadata about the page.
 type Fetcher interface {
@@ -22,20 +75,31 @@ type Fetcher interface {
 	// The `url` argument specifies the web page to be fetched.
 	// The `ctx` argument is a context for fetching the web page.
 	FetchPage(ctx context.Context, url string) ([]byte, error)
-	// SavePage saves the contents of a web page to a file on disk.
+	// Fetch fetches a web page like FetchPage, and returns it with its final URL and the redirects followed to it.
+	// The `url` argument specifies the web page to be fetched.
+	// The `ctx` argument is a context for fetching the web page.
+	Fetch(ctx context.Context, url string) (*Page, error)
+	// SavePage saves the contents of a web page to a file in the storage.
 	// The `filename` argument specifies the web page path to be saved.
 	// The `body` argument is a byte slice containing the contents of the web page.
 	SavePage(filename string, body []byte) error
 	// ExtractMetadata extracts metadata about a web page,
 	// such as the site url, number of links and images, assets url and last fetch.
 	// The `url` argument specifies the web page url in order to put in metadata.
-	// The `filePath` argument specifies file path for metadata JSON on disk.
-	ExtractMetadata(url, filePath string, file io.Reader) (*Metadata, error)
+	// The `filePath` argument specifies file path for metadata JSON in the storage.
+	// The `ctx` argument is a context for extracting the metadata, the metadata JSON isn't saved once it's canceled.
+	ExtractMetadata(ctx context.Context, url, filePath string, file io.Reader) (*Metadata, error)
+	// SaveMetadata overwrites the metadata JSON file in the storage with the given metadata,
+	// keeping the last fetch time recorded by ExtractMetadata.
+	// The `filePath` argument specifies file path for metadata JSON in the storage.
+	SaveMetadata(metadata *Metadata, filePath string) error
 	// StringMetadata return string of metadata such as site, number of links and images
 	// and last fetch to show on the console.
 	StringMetadata(metadata *Metadata) string
 	// Zip zips the given `filePaths` and `dirs` into a single archive file specified by `filename`.
-	Zip(filename string, filePaths, dirs []string) error
+	// Every path is a name in the storage and the entries are named after it.
+	// The `ctx` argument is a context for zipping, the partial archive is removed once it's canceled.
+	Zip(ctx context.Context, filename string, filePaths, dirs []string) error
 }
 
 // Compile time interface implementation check.
@@ -49,9 +113,25 @@ type HTTPClient interface {
 // client is a struct that implements the Fetcher and HTTPClient interfaces.
 // It contains an HTTPClient field that is used to make HTTP requests.
 type client struct {
-	httpClient HTTPClient
-	mutex      sync.Mutex
-	errChan    chan error
+	httpClient     HTTPClient
+	storage        storage.Storage
+	zipConcurrency int
+	// headers are sent with every request of FetchPage.
+	headers http.Header
+	// responseCheck rejects a response of FetchPage before its body is read, nil accepts every response.
+	responseCheck func(resp *http.Response) error
+	// timeout is the maximum duration of a request of FetchPage, including reading the body, 0 doesn't limit it.
+	timeout time.Duration
+	// idleTimeout is the maximum duration without receiving data from the server, 0 doesn't limit it.
+	idleTimeout time.Duration
+	// maxBodySize is the maximum size in bytes of a response body of FetchPage, 0 doesn't limit it.
+	maxBodySize int64
+	// contentTypes are the media types accepted by FetchPage, e.g. `text/html` or `image/*`, empty accepts every type.
+	contentTypes []string
+	// transport configures the connections of the HTTP client, the default HTTP client is used without settings.
+	transport transportConfig
+	// redirects is the redirect policy of the HTTP client, the default HTTP client is used without settings.
+	redirects redirectPolicy
 }
 
 // New returns an implementation of the Fetcher interface.
@@ -66,5 +146,20 @@ func New(opts ...Option) (Fetcher, error) {
 		}
 	}
 
+	// The connection and redirect options build the HTTP client, they can't configure a custom one.
+	if !c.transport.isZero() || !c.redirects.isZero() {
+		if c.httpClient != http.DefaultClient {
+			return nil, fmt.Errorf("failed to apply option: %w", errTransportHTTPClient)
+		}
+
+		httpClient := &http.Client{CheckRedirect: c.redirects.checkRedirect}
+
+		if !c.transport.isZero() {
+			httpClient.Transport = c.transport.roundTripper()
+		}
+
+		c.httpClient = httpClient
+	}
+
 	return c, nil
 }
@@ -37,18 +37,33 @@ func (m *GoMockClient) EXPECT() *GoMockClientMockRecorder {
 }
 
 // ExtractMetadata mocks base method.
-func (m *GoMockClient) ExtractMetadata(url, filePath string, file io.Reader) (*Metadata, error) {
+func (m *GoMockClient) ExtractMetadata(ctx context.Context, url, filePath string, file io.Reader) (*Metadata, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ExtractMetadata", url, filePath, file)
+	ret := m.ctrl.Call(m, "ExtractMetadata", ctx, url, filePath, file)
 	ret0, _ := ret[0].(*Metadata)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ExtractMetadata indicates an expected call of ExtractMetadata.
-func (mr *GoMockClientMockRecorder) ExtractMetadata(url, filePath, file interface{}) *gomock.Call {
+func (mr *GoMockClientMockRecorder) ExtractMetadata(ctx, url, filePath, file interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtractMetadata", reflect.TypeOf((*GoMockClient)(nil).ExtractMetadata), url, filePath, file)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtractMetadata", reflect.TypeOf((*GoMockClient)(nil).ExtractMetadata), ctx, url, filePath, file)
+}
+
+// Fetch mocks base method.
+func (m *GoMockClient) Fetch(ctx context.Context, url string) (*Page, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fetch", ctx, url)
+	ret0, _ := ret[0].(*Page)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Fetch indicates an expected call of Fetch.
+func (mr *GoMockClientMockRecorder) Fetch(ctx, url interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fetch", reflect.TypeOf((*GoMockClient)(nil).Fetch), ctx, url)
 }
 
 // FetchPage mocks base method.
@@ -66,6 +81,20 @@ func (mr *GoMockClientMockRecorder) FetchPage(ctx, url interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchPage", reflect.TypeOf((*GoMockClient)(nil).FetchPage), ctx, url)
 }
 
+// SaveMetadata mocks base method.
+func (m *GoMockClient) SaveMetadata(metadata *Metadata, filePath string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveMetadata", metadata, filePath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveMetadata indicates an expected call of SaveMetadata.
+func (mr *GoMockClientMockRecorder) SaveMetadata(metadata, filePath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMetadata", reflect.TypeOf((*GoMockClient)(nil).SaveMetadata), metadata, filePath)
+}
+
 // SavePage mocks base method.
 func (m *GoMockClient) SavePage(filename string, body []byte) error {
 	m.ctrl.T.Helper()
@@ -95,17 +124,17 @@ func (mr *GoMockClientMockRecorder) StringMetadata(metadata interface{}) *gomock
 }
 
 // Zip mocks base method.
-func (m *GoMockClient) Zip(filename string, filePaths, dirs []string) error {
+func (m *GoMockClient) Zip(ctx context.Context, filename string, filePaths, dirs []string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Zip", filename, filePaths, dirs)
+	ret := m.ctrl.Call(m, "Zip", ctx, filename, filePaths, dirs)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Zip indicates an expected call of Zip.
-func (mr *GoMockClientMockRecorder) Zip(filename, filePaths, dirs interface{}) *gomock.Call {
+func (mr *GoMockClientMockRecorder) Zip(ctx, filename, filePaths, dirs interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Zip", reflect.TypeOf((*GoMockClient)(nil).Zip), filename, filePaths, dirs)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Zip", reflect.TypeOf((*GoMockClient)(nil).Zip), ctx, filename, filePaths, dirs)
 }
 
 // MockHTTPClient is a mock of HTTPClient interface.
@@ -1,7 +1,9 @@
 package fetcher
 
 import (
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -39,6 +41,44 @@ func TestNew(t *testing.T) {
 				wantErr: errFailedSetHTTPClient,
 			}
 		},
+		"Successfully init New with connect timeout": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{
+					opts: []Option{
+						WithConnectTimeout(time.Second),
+					},
+				},
+				wantErr: nil,
+			}
+		},
+		"Failed init New with connect timeout and HTTP client": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{
+					opts: []Option{
+						WithHTTPClient(&http.Client{}),
+						WithConnectTimeout(time.Second),
+					},
+				},
+				wantErr: errTransportHTTPClient,
+			}
+		},
+		"Failed init New with redirect option and HTTP client": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{
+					opts: []Option{
+						WithHTTPClient(&http.Client{}),
+						WithNoFollowRedirects(),
+					},
+				},
+				wantErr: errTransportHTTPClient,
+			}
+		},
 	}
 
 	for name, fn := range tests {
@@ -0,0 +1,138 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// BlockedAddressError is returned by FetchPage when the host of the URL, or of one of its redirects,
+// resolves to an address denied by WithDenyPrivateNetworks.
+type BlockedAddressError struct {
+	// Addr is the address of the refused connection.
+	Addr netip.Addr
+	// Reason is the kind of the address, e.g. `loopback` or `private`.
+	Reason string
+}
+
+func (e *BlockedAddressError) Error() string {
+	return fmt.Sprintf("connection to %s address %s is blocked", e.Reason, e.Addr)
+}
+
+// deniedNetworks are the networks which aren't link-local, loopback or private for netip.Addr,
+// but reach internal services too.
+var deniedNetworks = []struct {
+	prefix netip.Prefix
+	reason string
+}{
+	{netip.MustParsePrefix("0.0.0.0/8"), "unspecified"},
+	// The shared address space of the carrier-grade NATs, it has the metadata service of some clouds, e.g. 100.100.100.200.
+	{netip.MustParsePrefix("100.64.0.0/10"), "shared"},
+	{netip.MustParsePrefix("192.0.0.0/24"), "reserved"},
+	// The translated IPv4 addresses of NAT64 may reach the private IPv4 networks.
+	{netip.MustParsePrefix("64:ff9b::/96"), "translated"},
+	{netip.MustParsePrefix("64:ff9b:1::/48"), "translated"},
+}
+
+// addressGuard refuses the connections to the private, loopback, link-local and metadata-service addresses,
+// except the allowed ones.
+type addressGuard struct {
+	allowed []netip.Prefix
+}
+
+// newAddressGuard returns a guard allowing the addresses and the networks, e.g. `10.0.0.5` or `10.0.0.0/8`.
+func newAddressGuard(allowed []string) (*addressGuard, error) {
+	guard := &addressGuard{allowed: make([]netip.Prefix, 0, len(allowed))}
+
+	for _, value := range allowed {
+		value = strings.TrimSpace(value)
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return nil, fmt.Errorf("%w: invalid network: %s", errFailedSetDenyPrivateNetworks, value)
+			}
+
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+
+		guard.allowed = append(guard.allowed, prefix.Masked())
+	}
+
+	return guard, nil
+}
+
+// control is the Control function of the dialer, it refuses the connection before it's established.
+// The address is the resolved IP of the host, so a host name can't resolve to a denied address.
+func (g *addressGuard) control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("failed to parse address: %s: %w", address, err)
+	}
+
+	return g.check(addrPort.Addr())
+}
+
+// checkHost resolves the host and returns a BlockedAddressError if one of its addresses is denied.
+func (g *addressGuard) checkHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve host: %s: %w", host, err)
+	}
+
+	for _, addr := range addrs {
+		if err := g.check(addr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// check returns a BlockedAddressError if the address is denied and isn't allowed.
+func (g *addressGuard) check(addr netip.Addr) error {
+	// An IPv4 address mapped to IPv6, e.g. ::ffff:127.0.0.1, is checked as IPv4.
+	addr = addr.Unmap()
+
+	reason := deniedReason(addr)
+	if reason == "" {
+		return nil
+	}
+
+	for _, prefix := range g.allowed {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+
+	return &BlockedAddressError{Addr: addr, Reason: reason}
+}
+
+// deniedReason returns the kind of the denied address, empty if the address is public.
+// The metadata services of the clouds, e.g. 169.254.169.254 and fd00:ec2::254, are link-local or private addresses.
+func deniedReason(addr netip.Addr) string {
+	switch {
+	case addr.IsLoopback():
+		return "loopback"
+	case addr.IsPrivate():
+		return "private"
+	case addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast(), addr.IsInterfaceLocalMulticast():
+		return "link-local"
+	case addr.IsUnspecified():
+		return "unspecified"
+	case addr.IsMulticast():
+		return "multicast"
+	}
+
+	for _, network := range deniedNetworks {
+		if network.prefix.Contains(addr) {
+			return network.reason
+		}
+	}
+
+	return ""
+}
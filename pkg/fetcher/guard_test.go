@@ -0,0 +1,181 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressGuard(t *testing.T) {
+	type test struct {
+		allowed []string
+		addr    string
+		wantErr error
+	}
+
+	blocked := func(addr, reason string) error {
+		return &BlockedAddressError{Addr: netip.MustParseAddr(addr), Reason: reason}
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully check public address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{addr: "93.184.216.34"}
+		},
+		"Successfully check public IPv6 address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{addr: "2606:2800:220:1:248:1893:25c8:1946"}
+		},
+		"Successfully check allowed network": func(t *testing.T) test {
+			t.Helper()
+
+			return test{allowed: []string{"10.0.0.0/8"}, addr: "10.1.2.3"}
+		},
+		"Successfully check allowed address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{allowed: []string{"127.0.0.1"}, addr: "127.0.0.1"}
+		},
+		"Failed check loopback address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{allowed: []string{"127.0.0.2"}, addr: "127.0.0.1", wantErr: blocked("127.0.0.1", "loopback")}
+		},
+		"Failed check IPv6 loopback address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{addr: "::1", wantErr: blocked("::1", "loopback")}
+		},
+		"Failed check mapped loopback address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{addr: "::ffff:127.0.0.1", wantErr: blocked("127.0.0.1", "loopback")}
+		},
+		"Failed check private address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{addr: "192.168.1.1", wantErr: blocked("192.168.1.1", "private")}
+		},
+		"Failed check metadata service address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{addr: "169.254.169.254", wantErr: blocked("169.254.169.254", "link-local")}
+		},
+		"Failed check IPv6 metadata service address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{addr: "fd00:ec2::254", wantErr: blocked("fd00:ec2::254", "private")}
+		},
+		"Failed check shared address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{addr: "100.100.100.200", wantErr: blocked("100.100.100.200", "shared")}
+		},
+		"Failed check unspecified address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{addr: "0.0.0.0", wantErr: blocked("0.0.0.0", "unspecified")}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			guard, err := newAddressGuard(tt.allowed)
+			require.NoError(t, err)
+
+			err = guard.control("tcp", net.JoinHostPort(tt.addr, "80"), nil)
+
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestFetchPageDenyPrivateNetworks(t *testing.T) {
+	// The internal server listens on another loopback address, so it isn't allowed with the first server.
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("127.0.0.2 isn't available: %v", err)
+	}
+
+	internal := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("internal"))
+	}))
+	internal.Listener = listener
+	internal.Start()
+
+	defer internal.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, internal.URL, http.StatusFound)
+
+			return
+		}
+
+		_, _ = w.Write([]byte("page"))
+	}))
+
+	defer server.Close()
+
+	type test struct {
+		allowed []string
+		url     string
+		want    []byte
+		wantErr error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully fetch page of allowed address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{allowed: []string{"127.0.0.1"}, url: server.URL, want: []byte("page")}
+		},
+		"Failed fetch page of loopback address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{url: server.URL, wantErr: &BlockedAddressError{Addr: netip.MustParseAddr("127.0.0.1"), Reason: "loopback"}}
+		},
+		"Failed fetch page redirected to loopback address": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				allowed: []string{"127.0.0.1"},
+				url:     server.URL + "/redirect",
+				wantErr: &BlockedAddressError{Addr: netip.MustParseAddr("127.0.0.2"), Reason: "loopback"},
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			c, err := New(WithDenyPrivateNetworks(tt.allowed...))
+			require.NoError(t, err)
+
+			body, err := c.FetchPage(context.Background(), tt.url)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, body)
+
+				return
+			}
+
+			var blockedErr *BlockedAddressError
+
+			require.True(t, errors.As(err, &blockedErr), err)
+			assert.Equal(t, tt.wantErr, blockedErr)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package fetcher
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// BodySizeError is returned by FetchPage when the response body is larger than the max body size of the client.
+type BodySizeError struct {
+	// Limit is the max body size in bytes.
+	Limit int64
+}
+
+func (e *BodySizeError) Error() string {
+	return fmt.Sprintf("response body exceeds the limit of %d bytes", e.Limit)
+}
+
+// ContentTypeError is returned by FetchPage when the content type of the response isn't one of the client.
+type ContentTypeError struct {
+	// ContentType is the media type of the response, without its parameters.
+	ContentType string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected content type: %s", e.ContentType)
+}
+
+// checkResponse checks the content type and the Content-Length of the response before its body is read.
+// A response without a Content-Type header is accepted, its body is checked while it's read.
+func (c *client) checkResponse(resp *http.Response) error {
+	if contentType := resp.Header.Get("Content-Type"); len(c.contentTypes) > 0 && contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+		}
+
+		if !matchContentType(c.contentTypes, mediaType) {
+			return &ContentTypeError{ContentType: mediaType}
+		}
+	}
+
+	if c.maxBodySize > 0 && resp.ContentLength > c.maxBodySize {
+		return &BodySizeError{Limit: c.maxBodySize}
+	}
+
+	return nil
+}
+
+// readBody reads the body up to the max body size, a larger body is aborted with a BodySizeError.
+func (c *client) readBody(reader io.Reader) ([]byte, error) {
+	if c.maxBodySize == 0 {
+		return io.ReadAll(reader)
+	}
+
+	// One more byte is read to know whether the body is larger than the limit.
+	body, err := io.ReadAll(io.LimitReader(reader, c.maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > c.maxBodySize {
+		return nil, &BodySizeError{Limit: c.maxBodySize}
+	}
+
+	return body, nil
+}
+
+// matchContentType reports whether the media type is one of the content types,
+// `type/*` matches every subtype and `*/*` matches every media type.
+func matchContentType(contentTypes []string, mediaType string) bool {
+	for _, contentType := range contentTypes {
+		switch {
+		case contentType == "*/*", contentType == mediaType:
+			return true
+		case strings.HasSuffix(contentType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(contentType, "*")):
+			return true
+		}
+	}
+
+	return false
+}
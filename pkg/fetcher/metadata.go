@@ -1,17 +1,23 @@
 package fetcher
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/moemoe89/fetch/pkg/storage"
 	"golang.org/x/net/html"
 )
 
+// MetadataFilename is the filename of the metadata JSON saved in the page directory.
+const MetadataFilename = "_metadata.json"
+
 // targetMetadata is a map of HTML tags whose attributes (e.g., "src" or "href") should be extracted.
 var targetMetadata = map[string]bool{
 	"img":    true,
@@ -27,20 +33,71 @@ var targetAttr = map[string]bool{
 
 // Metadata data structure for the metadata web page.
 type Metadata struct {
-	Site      string    `json:"site"`
-	NumLinks  int64     `json:"num_links"`
-	Images    int64     `json:"images"`
-	Assets    []string  `json:"assets"`
+	Site     string   `json:"site"`
+	Title    string   `json:"title,omitempty"`
+	NumLinks int64    `json:"num_links"`
+	Images   int64    `json:"images"`
+	Assets   []string `json:"assets"`
+	// Links are the href attributes of the anchors of the page.
+	Links     []string  `json:"links,omitempty"`
 	LastFetch time.Time `json:"last_fetch"`
+	// Checksums maps the zip entry name of the page and its assets to the SHA-256 hex digest of the content.
+	Checksums map[string]string `json:"checksums,omitempty"`
+	// AssetDigests maps the asset URL as referenced in the page to the SHA-256 hex digest of the asset in the store.
+	AssetDigests map[string]string `json:"asset_digests,omitempty"`
+	// Skipped are the assets of the page skipped by the filter rules, they aren't saved.
+	Skipped []Skipped `json:"skipped,omitempty"`
+	// Failed are the assets of the page which couldn't be fetched or saved, they keep their original link.
+	Failed []FailedAsset `json:"failed,omitempty"`
+	// FinalURL is the URL of the page after following the redirects from the site, empty without redirect.
+	FinalURL string `json:"final_url,omitempty"`
+	// Redirects are the redirects followed from the site to the final URL.
+	Redirects []Redirect `json:"redirects,omitempty"`
+	// TLS is the summary of the TLS connection of the page, with the certificates of the server, nil without TLS.
+	TLS *TLSInfo `json:"tls,omitempty"`
+}
+
+// PageURL returns the URL the links and the assets of the page are relative to, the final URL after the redirects.
+func (m *Metadata) PageURL() string {
+	if m.FinalURL != "" {
+		return m.FinalURL
+	}
+
+	return m.Site
+}
+
+// Skipped is an asset skipped by the filter rules, with the reason.
+type Skipped struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// FailedAsset is an asset which couldn't be fetched or saved, with the reason.
+type FailedAsset struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+	// Status is the HTTP status code of the response, 0 if there is no response.
+	Status int `json:"status,omitempty"`
+}
+
+// Checksum returns the SHA-256 hex digest of the content, as recorded in Metadata.Checksums.
+func Checksum(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return hex.EncodeToString(sum[:])
 }
 
 // ExtractMetadata parses an HTML document from the given io.Reader
 // and returns a slice of the values of "src" or "href" attributes for the HTML tags specified in targetMetadata.
-func (c *client) ExtractMetadata(url, filePath string, file io.Reader) (*Metadata, error) {
+func (c *client) ExtractMetadata(ctx context.Context, url, filePath string, file io.Reader) (*Metadata, error) {
 	metadata := &Metadata{
 		Site: url,
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to extract metadata: %w", err)
+	}
+
 	err := c.parseHTML(metadata, file)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %w", err)
@@ -51,6 +108,10 @@ func (c *client) ExtractMetadata(url, filePath string, file io.Reader) (*Metadat
 		return nil, fmt.Errorf("failed to tokenizer HTML: %w", err)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to extract metadata: %w", err)
+	}
+
 	// Save metadata to JSON file.
 	err = c.saveMetadataJSON(metadata, filePath)
 	if err != nil {
@@ -60,6 +121,17 @@ func (c *client) ExtractMetadata(url, filePath string, file io.Reader) (*Metadat
 	return metadata, nil
 }
 
+// Links returns the href attributes of the anchors of the HTML document, e.g. to follow the links of a page.
+func Links(file io.Reader) ([]string, error) {
+	metadata := &Metadata{}
+
+	if err := new(client).parseHTML(metadata, file); err != nil {
+		return nil, err
+	}
+
+	return metadata.Links, nil
+}
+
 // parseHTML parses HTML
 func (c *client) parseHTML(metadata *Metadata, file io.Reader) error {
 	doc, err := html.Parse(file)
@@ -70,6 +142,19 @@ func (c *client) parseHTML(metadata *Metadata, file io.Reader) error {
 	var links []string
 	var f func(*html.Node)
 	f = func(n *html.Node) {
+		// Collects the title and the anchors to compare the snapshots of the page.
+		if n.Type == html.ElementNode && n.Data == "title" && metadata.Title == "" && n.FirstChild != nil {
+			metadata.Title = strings.TrimSpace(n.FirstChild.Data)
+		}
+
+		if n.Type == html.ElementNode && n.Data == "a" {
+			for _, a := range n.Attr {
+				if a.Key == "href" && a.Val != "" {
+					metadata.Links = append(metadata.Links, a.Val)
+				}
+			}
+		}
+
 		if n.Type == html.ElementNode && targetMetadata[n.Data] {
 			for _, a := range n.Attr {
 				if strings.Contains(a.Val, "base64") {
@@ -157,25 +242,68 @@ func (c *client) countAssets(metadata *Metadata, tokenData string) {
 
 // saveMetadataJSON saves the metadata to JSON file.
 func (c *client) saveMetadataJSON(metadata *Metadata, filePath string) error {
-	var lastFetch time.Time
-
 	// Checks if the metadata already exists.
 	// If exists, gets the last fetch time
-	metadataFile, err := os.ReadFile(filePath)
-	if err == nil {
-		var lastMetadata *Metadata
-
-		err = json.Unmarshal(metadataFile, &lastMetadata)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal metadata: %w", err)
-		}
-
-		lastFetch = lastMetadata.LastFetch
+	lastFetch, err := c.readLastFetch(filePath)
+	if err != nil {
+		return err
 	}
 
 	// Updates last fetch.
 	metadata.LastFetch = time.Now().UTC()
 
+	err = c.writeMetadataJSON(metadata, filePath)
+	if err != nil {
+		return err
+	}
+
+	// Update metadata last fetch.
+	metadata.LastFetch = lastFetch
+
+	return nil
+}
+
+// SaveMetadata overwrites the metadata JSON file, e.g. after the checksums are recorded.
+// The last fetch time stored in the file is kept as is.
+func (c *client) SaveMetadata(metadata *Metadata, filePath string) error {
+	fetchedAt, err := c.readLastFetch(filePath)
+	if err != nil {
+		return err
+	}
+
+	if fetchedAt.IsZero() {
+		fetchedAt = time.Now().UTC()
+	}
+
+	saved := *metadata
+	saved.LastFetch = fetchedAt
+
+	return c.writeMetadataJSON(&saved, filePath)
+}
+
+// readLastFetch returns the last fetch time of the metadata JSON file, or zero time if the file doesn't exist.
+func (c *client) readLastFetch(filePath string) (time.Time, error) {
+	metadataFile, err := c.storage.ReadFile(filePath)
+	if errors.Is(err, storage.ErrNotExist) {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	var lastMetadata *Metadata
+
+	err = json.Unmarshal(metadataFile, &lastMetadata)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+
+	return lastMetadata.LastFetch, nil
+}
+
+// writeMetadataJSON marshals the metadata and writes it to the JSON file.
+func (c *client) writeMetadataJSON(metadata *Metadata, filePath string) error {
 	metadataJSON, err := json.MarshalIndent(metadata, "", " ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal indent metadata: %w", err)
@@ -187,9 +315,6 @@ func (c *client) saveMetadataJSON(metadata *Metadata, filePath string) error {
 		return fmt.Errorf("failed to save metadata json: %w", err)
 	}
 
-	// Update metadata last fetch.
-	metadata.LastFetch = lastFetch
-
 	return nil
 }
 
@@ -202,8 +327,13 @@ func (c *client) StringMetadata(metadata *Metadata) string {
 		lastFetch = metadata.LastFetch.Format("Mon Jan 02 2006 15:04 MST")
 	}
 
+	site := metadata.Site
+	if metadata.FinalURL != "" {
+		site += " -> " + metadata.FinalURL
+	}
+
 	return fmt.Sprintf("site: %s\nnum_links: %d\nimages: %d\nlast_fetch: %s\n\n",
-		metadata.Site,
+		site,
 		metadata.NumLinks,
 		metadata.Images,
 		lastFetch,
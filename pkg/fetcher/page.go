@@ -5,38 +5,115 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 )
 
+// StatusError is returned by CheckStatus when the response status isn't successful.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// CheckStatus returns a StatusError if the response status isn't 2xx, e.g. as a check of WithResponseCheck.
+func CheckStatus(resp *http.Response) error {
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// Page is a page fetched by Fetch.
+type Page struct {
+	// URL is the final URL of the page, after following the redirects.
+	URL  string
+	Body []byte
+	// Redirects are the redirects followed from the requested URL to the final URL, empty without redirect.
+	Redirects []Redirect
+	// TLS is the summary of the TLS connection of the final URL, nil without TLS.
+	TLS *TLSInfo
+}
+
 // FetchPage makes a GET request to the specified URL and returns the response body.
 func (c *client) FetchPage(ctx context.Context, url string) ([]byte, error) {
+	page, err := c.Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Body, nil
+}
+
+// Fetch makes a GET request to the specified URL and returns the page with its final URL and redirects.
+// An expired timeout of the client is returned as a TimeoutError, a body larger than its max body size
+// as a BodySizeError, a content type which isn't one of its content types as a ContentTypeError
+// a redirect refused by its redirect policy as a RedirectError and a certificate which doesn't match
+// its pinned public keys as a CertificatePinError.
+func (c *client) Fetch(ctx context.Context, url string) (*Page, error) {
+	ctx, idle, stop := c.startTimeouts(ctx)
+	defer stop()
+
 	// Create a new HTTP request with the given URL.
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
+	for name, values := range c.headers {
+		// The Host header is sent from the request host.
+		if http.CanonicalHeaderKey(name) == "Host" && len(values) > 0 {
+			req.Host = values[0]
+
+			continue
+		}
+
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+
 	// Make the GET request.
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to do HTTP request: %w", err)
+		return nil, fmt.Errorf("failed to do HTTP request: %w", c.timeoutError(ctx, err))
 	}
 
 	defer func() { _ = resp.Body.Close() }()
 
+	if c.responseCheck != nil {
+		if err := c.responseCheck(resp); err != nil {
+			return nil, fmt.Errorf("failed to check response: %w", err)
+		}
+	}
+
+	if err := c.checkResponse(resp); err != nil {
+		return nil, fmt.Errorf("failed to check response: %w", err)
+	}
+
+	reader := io.Reader(resp.Body)
+	if idle != nil {
+		reader = &idleReader{reader: resp.Body, timer: idle, timeout: c.idleTimeout}
+	}
+
 	// Read all the data from the response body.
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.readBody(reader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read body: %w", err)
+		return nil, fmt.Errorf("failed to read body: %w", c.timeoutError(ctx, err))
+	}
+
+	page := &Page{URL: url, Body: body, Redirects: redirectChain(resp), TLS: newTLSInfo(resp.TLS)}
+
+	if resp.Request != nil {
+		page.URL = resp.Request.URL.String()
 	}
 
-	return body, nil
+	return page, nil
 }
 
-// SavePage writes the provided body to a file with the specified filename.
+// SavePage writes the provided body to a file with the specified filename in the storage.
 func (c *client) SavePage(filename string, body []byte) error {
 	// Write the body to the file.
-	err := os.WriteFile(filename, body, 0644)
+	err := c.storage.WriteFile(filename, body)
 	if err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
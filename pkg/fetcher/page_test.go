@@ -0,0 +1,217 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckStatus(t *testing.T) {
+	type test struct {
+		statusCode int
+		wantErr    error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully check OK status": func(t *testing.T) test {
+			t.Helper()
+
+			return test{statusCode: http.StatusOK}
+		},
+		"Successfully check no content status": func(t *testing.T) test {
+			t.Helper()
+
+			return test{statusCode: http.StatusNoContent}
+		},
+		"Failed check not found status": func(t *testing.T) test {
+			t.Helper()
+
+			return test{statusCode: http.StatusNotFound, wantErr: &StatusError{StatusCode: http.StatusNotFound}}
+		},
+		"Failed check redirect status": func(t *testing.T) test {
+			t.Helper()
+
+			return test{statusCode: http.StatusNotModified, wantErr: &StatusError{StatusCode: http.StatusNotModified}}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			err := CheckStatus(&http.Response{StatusCode: tt.statusCode})
+
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+
+	assert.Equal(t, "unexpected status: 404 Not Found", (&StatusError{StatusCode: http.StatusNotFound}).Error())
+}
+
+func TestFetchPageTimeout(t *testing.T) {
+	// The server sends the first part of the body, then stalls until the test ends.
+	done := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html>"))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+
+	defer server.Close()
+	defer close(done)
+
+	type test struct {
+		opts    []Option
+		wantErr *TimeoutError
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Failed fetch page with request timeout": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:    []Option{WithTimeout(50 * time.Millisecond)},
+				wantErr: &TimeoutError{Kind: TimeoutRequest, Duration: 50 * time.Millisecond},
+			}
+		},
+		"Failed fetch page with idle timeout": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:    []Option{WithTimeout(time.Minute), WithIdleTimeout(50 * time.Millisecond)},
+				wantErr: &TimeoutError{Kind: TimeoutIdle, Duration: 50 * time.Millisecond},
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			c, err := New(tt.opts...)
+			require.NoError(t, err)
+
+			_, err = c.FetchPage(context.Background(), server.URL)
+
+			var timeoutErr *TimeoutError
+
+			require.True(t, errors.As(err, &timeoutErr), err)
+			assert.Equal(t, tt.wantErr, timeoutErr)
+			assert.True(t, timeoutErr.Timeout())
+		})
+	}
+}
+
+func TestFetchPageLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html></html>"))
+		case "/file.bin":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte("binary"))
+		case "/chunked.html":
+			// The flush sends the body without a Content-Length, so the size is only known while reading it.
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html>"))
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte("</html>"))
+		}
+	}))
+
+	defer server.Close()
+
+	type test struct {
+		opts    []Option
+		path    string
+		want    []byte
+		wantErr error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully fetch page with content type and max body size": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts: []Option{WithContentTypes("text/html"), WithMaxBodySize(13)},
+				path: "/page.html",
+				want: []byte("<html></html>"),
+			}
+		},
+		"Successfully fetch page with any content type": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts: []Option{WithContentTypes("*/*")},
+				path: "/file.bin",
+				want: []byte("binary"),
+			}
+		},
+		"Failed fetch page with unexpected content type": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:    []Option{WithContentTypes("text/html", "application/xhtml+xml")},
+				path:    "/file.bin",
+				wantErr: &ContentTypeError{ContentType: "application/octet-stream"},
+			}
+		},
+		"Failed fetch page with larger Content-Length": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:    []Option{WithMaxBodySize(12)},
+				path:    "/page.html",
+				wantErr: &BodySizeError{Limit: 12},
+			}
+		},
+		"Failed fetch page with larger body": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:    []Option{WithMaxBodySize(12)},
+				path:    "/chunked.html",
+				wantErr: &BodySizeError{Limit: 12},
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			c, err := New(tt.opts...)
+			require.NoError(t, err)
+
+			body, err := c.FetchPage(context.Background(), server.URL+tt.path)
+
+			switch want := tt.wantErr.(type) {
+			case *ContentTypeError:
+				var contentTypeErr *ContentTypeError
+
+				require.True(t, errors.As(err, &contentTypeErr), err)
+				assert.Equal(t, want, contentTypeErr)
+			case *BodySizeError:
+				var bodySizeErr *BodySizeError
+
+				require.True(t, errors.As(err, &bodySizeErr), err)
+				assert.Equal(t, want, bodySizeErr)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// defaultMaxRedirects is the maximum number of redirects followed without WithMaxRedirects, as http.Client.
+const defaultMaxRedirects = 10
+
+// Redirect is a redirect response followed by Fetch.
+type Redirect struct {
+	// URL is the URL which answered the redirect.
+	URL string `json:"url"`
+	// Status is the HTTP status code of the redirect, e.g. 301.
+	Status int `json:"status"`
+}
+
+// RedirectError is returned by Fetch when a redirect is refused by the redirect policy of the client.
+type RedirectError struct {
+	// URL is the target of the refused redirect.
+	URL string
+	// Reason is the rule of the policy refusing the redirect.
+	Reason string
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("redirect to %s refused: %s", e.URL, e.Reason)
+}
+
+// redirectPolicy is the policy of the redirects of the HTTP client built by New.
+type redirectPolicy struct {
+	// max is the maximum number of redirects when limited is set, defaultMaxRedirects otherwise.
+	max     int
+	limited bool
+	// sameHost refuses the redirects to another host than the requested URL.
+	sameHost bool
+	// noFollow returns the redirect response instead of following it.
+	noFollow bool
+}
+
+// isZero reports whether the policy has no setting, so the default HTTP client is used.
+func (p redirectPolicy) isZero() bool {
+	return p == redirectPolicy{}
+}
+
+// checkRedirect is the CheckRedirect function of the HTTP client, `via` are the requests already made.
+func (p redirectPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if p.noFollow {
+		return http.ErrUseLastResponse
+	}
+
+	maxRedirects := defaultMaxRedirects
+	if p.limited {
+		maxRedirects = p.max
+	}
+
+	if len(via) > maxRedirects {
+		return &RedirectError{URL: req.URL.String(), Reason: fmt.Sprintf("more than %d redirects", maxRedirects)}
+	}
+
+	if p.sameHost && req.URL.Host != via[0].URL.Host {
+		return &RedirectError{URL: req.URL.String(), Reason: "another host than " + via[0].URL.Host}
+	}
+
+	return nil
+}
+
+// redirectChain returns the redirects followed to get the response, from the requested URL to the final one.
+func redirectChain(resp *http.Response) []Redirect {
+	var chain []Redirect
+
+	// Every request of a redirect has the response which caused it.
+	for req := resp.Request; req != nil && req.Response != nil && req.Response.Request != nil; req = req.Response.Request {
+		chain = append(chain, Redirect{URL: req.Response.Request.URL.String(), Status: req.Response.StatusCode})
+	}
+
+	// The chain is built from the final request, it's reversed to start with the requested URL.
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+
+	return chain
+}
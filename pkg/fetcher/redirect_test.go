@@ -0,0 +1,135 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchRedirects(t *testing.T) {
+	// The other server has another host, 127.0.0.1 and localhost resolve to the same loopback address.
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("other"))
+	}))
+
+	defer other.Close()
+
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "/docs/page.html", http.StatusFound)
+		case "/external":
+			http.Redirect(w, r, otherURL, http.StatusFound)
+		default:
+			_, _ = w.Write([]byte("page"))
+		}
+	}))
+
+	defer server.Close()
+
+	type test struct {
+		opts    []Option
+		path    string
+		want    *Page
+		wantErr *RedirectError
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully fetch page with redirects": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				path: "/old",
+				want: &Page{
+					URL:  server.URL + "/docs/page.html",
+					Body: []byte("page"),
+					Redirects: []Redirect{
+						{URL: server.URL + "/old", Status: http.StatusMovedPermanently},
+						{URL: server.URL + "/moved", Status: http.StatusFound},
+					},
+				},
+			}
+		},
+		"Successfully fetch page without redirect": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts: []Option{WithMaxRedirects(0)},
+				path: "/docs/page.html",
+				want: &Page{URL: server.URL + "/docs/page.html", Body: []byte("page")},
+			}
+		},
+		"Successfully fetch redirect without following it": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts: []Option{WithNoFollowRedirects()},
+				path: "/moved",
+				want: &Page{URL: server.URL + "/moved", Body: []byte("<a href=\"/docs/page.html\">Found</a>.\n\n")},
+			}
+		},
+		"Successfully fetch page with redirect to same host": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts: []Option{WithSameHostRedirects()},
+				path: "/moved",
+				want: &Page{
+					URL:       server.URL + "/docs/page.html",
+					Body:      []byte("page"),
+					Redirects: []Redirect{{URL: server.URL + "/moved", Status: http.StatusFound}},
+				},
+			}
+		},
+		"Failed fetch page with too many redirects": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:    []Option{WithMaxRedirects(1)},
+				path:    "/old",
+				wantErr: &RedirectError{URL: server.URL + "/docs/page.html", Reason: "more than 1 redirects"},
+			}
+		},
+		"Failed fetch page with redirect to another host": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:    []Option{WithSameHostRedirects()},
+				path:    "/external",
+				wantErr: &RedirectError{URL: otherURL, Reason: "another host than " + strings.TrimPrefix(server.URL, "http://")},
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			c, err := New(tt.opts...)
+			require.NoError(t, err)
+
+			page, err := c.Fetch(context.Background(), server.URL+tt.path)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, page)
+
+				return
+			}
+
+			var redirectErr *RedirectError
+
+			require.True(t, errors.As(err, &redirectErr), err)
+			assert.Equal(t, tt.wantErr, redirectErr)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Timeouts of TimeoutError.
+const (
+	// TimeoutConnect is the timeout of establishing the connection to the server.
+	TimeoutConnect = "connect"
+	// TimeoutIdle is the timeout of waiting for data from the server.
+	TimeoutIdle = "idle"
+	// TimeoutRequest is the timeout of the whole request, including reading the body.
+	TimeoutRequest = "request"
+)
+
+// TimeoutError is returned by FetchPage when one of the timeouts of the client expires.
+type TimeoutError struct {
+	// Kind is the timeout which expired: TimeoutConnect, TimeoutIdle or TimeoutRequest.
+	Kind string
+	// Duration is the timeout which expired.
+	Duration time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout of %s exceeded", e.Kind, e.Duration)
+}
+
+// Timeout reports whether the error is a timeout, like net.Error, it's always true.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// idleReader cancels the request with a TimeoutError when no data is read for the idle timeout.
+type idleReader struct {
+	reader  io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+
+	return n, err
+}
+
+// startTimeouts returns the context of a request canceled with a TimeoutError by the request and idle timeouts,
+// and the idle timer to reset when data is received, nil without idle timeout.
+// The returned stop function releases the timers and the context.
+func (c *client) startTimeouts(ctx context.Context) (context.Context, *time.Timer, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	var timers []*time.Timer
+
+	if c.timeout > 0 {
+		timers = append(timers, time.AfterFunc(c.timeout, func() {
+			cancel(&TimeoutError{Kind: TimeoutRequest, Duration: c.timeout})
+		}))
+	}
+
+	var idle *time.Timer
+
+	if c.idleTimeout > 0 {
+		idle = time.AfterFunc(c.idleTimeout, func() {
+			cancel(&TimeoutError{Kind: TimeoutIdle, Duration: c.idleTimeout})
+		})
+
+		timers = append(timers, idle)
+	}
+
+	return ctx, idle, func() {
+		for _, timer := range timers {
+			timer.Stop()
+		}
+
+		cancel(nil)
+	}
+}
+
+// timeoutError returns the TimeoutError which caused the error of the request, or the error itself.
+func (c *client) timeoutError(ctx context.Context, err error) error {
+	var timeoutErr *TimeoutError
+	if errors.As(context.Cause(ctx), &timeoutErr) {
+		return timeoutErr
+	}
+
+	var opErr *net.OpError
+	if c.transport.connectTimeout > 0 && errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout() {
+		return &TimeoutError{Kind: TimeoutConnect, Duration: c.transport.connectTimeout}
+	}
+
+	return err
+}
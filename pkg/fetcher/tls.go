@@ -0,0 +1,197 @@
+package fetcher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// tlsVersions are the names of the TLS versions, e.g. `1.2` for WithMinTLSVersion.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSInfo is the summary of the TLS connection of a fetched page.
+type TLSInfo struct {
+	// Version is the TLS version of the connection, e.g. `1.3`.
+	Version string `json:"version"`
+	// CipherSuite is the name of the cipher suite of the connection.
+	CipherSuite string `json:"cipher_suite"`
+	// Verified reports whether the certificate chain was verified, false with WithInsecureSkipVerify.
+	Verified bool `json:"verified"`
+	// Certificates are the certificates sent by the server, starting with the certificate of the server.
+	Certificates []Certificate `json:"certificates"`
+}
+
+// Certificate is the summary of a certificate of the server.
+type Certificate struct {
+	Subject string `json:"subject"`
+	Issuer  string `json:"issuer"`
+	// NotBefore and NotAfter are the validity period of the certificate, NotAfter is its expiry.
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	// DNSNames and IPAddresses are the subject alternative names of the certificate.
+	DNSNames    []string `json:"dns_names,omitempty"`
+	IPAddresses []string `json:"ip_addresses,omitempty"`
+	// SHA256 is the hex fingerprint of the certificate.
+	SHA256 string `json:"sha256"`
+}
+
+// CertificatePinError is returned by FetchPage when no certificate of the server matches the pinned public keys.
+type CertificatePinError struct {
+	// Subject is the subject of the certificate of the server.
+	Subject string
+}
+
+func (e *CertificatePinError) Error() string {
+	return fmt.Sprintf("certificate %s doesn't match the pinned public keys", e.Subject)
+}
+
+// tlsSettings configures the TLS connections of the HTTP client built by New.
+type tlsSettings struct {
+	// rootCAs are the system roots with the added CA certificates, nil uses the system roots.
+	rootCAs      *x509.CertPool
+	certificates []tls.Certificate
+	// minVersion is the minimum TLS version, 0 uses the default of crypto/tls.
+	minVersion         uint16
+	insecureSkipVerify bool
+	// pins are the SHA-256 hashes of the pinned public keys, empty accepts every key.
+	pins [][]byte
+}
+
+// config returns the TLS config of the transport.
+func (s *tlsSettings) config() *tls.Config {
+	config := &tls.Config{
+		RootCAs:      s.rootCAs,
+		Certificates: s.certificates,
+		MinVersion:   s.minVersion,
+		// The certificates aren't verified on purpose, the CLI warns about it.
+		InsecureSkipVerify: s.insecureSkipVerify, //nolint:gosec
+	}
+
+	if len(s.pins) > 0 {
+		config.VerifyConnection = s.verifyPins
+	}
+
+	return config
+}
+
+// addCACertificates adds the PEM certificates of the bundle to the system roots.
+func (s *tlsSettings) addCACertificates(bundle []byte) error {
+	if s.rootCAs == nil {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		s.rootCAs = pool
+	}
+
+	if !s.rootCAs.AppendCertsFromPEM(bundle) {
+		return errors.New("no PEM certificate found")
+	}
+
+	return nil
+}
+
+// verifyPins is the VerifyConnection function checking the pinned public keys.
+// A key of the verified chains must be pinned, or without verification the key of the certificate of the server,
+// so a pinned certificate merely appended by the server to its chain is never accepted.
+func (s *tlsSettings) verifyPins(state tls.ConnectionState) error {
+	var certificates []*x509.Certificate
+
+	if s.insecureSkipVerify {
+		if len(state.PeerCertificates) > 0 {
+			certificates = state.PeerCertificates[:1]
+		}
+	} else {
+		for _, chain := range state.VerifiedChains {
+			certificates = append(certificates, chain...)
+		}
+	}
+
+	for _, certificate := range certificates {
+		hash := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+
+		for _, pin := range s.pins {
+			if bytes.Equal(hash[:], pin) {
+				return nil
+			}
+		}
+	}
+
+	pinErr := &CertificatePinError{}
+	if len(state.PeerCertificates) > 0 {
+		pinErr.Subject = state.PeerCertificates[0].Subject.String()
+	}
+
+	return pinErr
+}
+
+// parsePin parses a pinned public key, the base64 SHA-256 hash of the key as `sha256//<hash>`.
+func parsePin(value string) ([]byte, error) {
+	encoded := strings.TrimPrefix(strings.TrimSpace(value), "sha256//")
+
+	pin, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(pin) != sha256.Size {
+		return nil, fmt.Errorf("%w: invalid pin: %s", errFailedSetCertificatePins, value)
+	}
+
+	return pin, nil
+}
+
+// newTLSInfo returns the summary of the TLS connection, nil without TLS.
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+
+	info := &TLSInfo{
+		Version:      tlsVersionName(state.Version),
+		CipherSuite:  tls.CipherSuiteName(state.CipherSuite),
+		Verified:     len(state.VerifiedChains) > 0,
+		Certificates: make([]Certificate, 0, len(state.PeerCertificates)),
+	}
+
+	for _, certificate := range state.PeerCertificates {
+		fingerprint := sha256.Sum256(certificate.Raw)
+
+		summary := Certificate{
+			Subject:   certificate.Subject.String(),
+			Issuer:    certificate.Issuer.String(),
+			NotBefore: certificate.NotBefore.UTC(),
+			NotAfter:  certificate.NotAfter.UTC(),
+			DNSNames:  certificate.DNSNames,
+			SHA256:    hex.EncodeToString(fingerprint[:]),
+		}
+
+		for _, ip := range certificate.IPAddresses {
+			summary.IPAddresses = append(summary.IPAddresses, ip.String())
+		}
+
+		info.Certificates = append(info.Certificates, summary)
+	}
+
+	return info
+}
+
+// tlsVersionName returns the name of the TLS version, e.g. `1.3`.
+func tlsVersionName(version uint16) string {
+	for name, value := range tlsVersions {
+		if value == version {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("0x%04x", version)
+}
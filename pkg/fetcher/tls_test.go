@@ -0,0 +1,413 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self-signed PEM certificate and its key to the directory, and returns their files.
+func writeCertificate(t *testing.T, dir, name string) (string, string, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+".key")
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile, certificate
+}
+
+func TestFetchTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	clientCert, clientKey, client := writeCertificate(t, dir, "client")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(client)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		_, _ = w.Write([]byte("page"))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.VerifyClientCertIfGiven, ClientCAs: clientCAs, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+
+	defer server.Close()
+
+	// The certificate of the test server is its own CA.
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+	pin := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	otherPin := sha256.Sum256([]byte("other"))
+
+	type test struct {
+		opts         []Option
+		wantVerified bool
+		wantErr      error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully fetch page with CA certificate and client certificate": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:         []Option{WithCACertificates(caFile), WithClientCertificate(clientCert, clientKey), WithMinTLSVersion("1.2")},
+				wantVerified: true,
+			}
+		},
+		"Successfully fetch page with pinned public key": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts: []Option{
+					WithCACertificates(caFile),
+					WithClientCertificate(clientCert, clientKey),
+					WithCertificatePins("sha256//" + base64.StdEncoding.EncodeToString(pin[:])),
+				},
+				wantVerified: true,
+			}
+		},
+		"Successfully fetch page without verification": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:         []Option{WithInsecureSkipVerify(), WithClientCertificate(clientCert, clientKey)},
+				wantVerified: false,
+			}
+		},
+		"Failed fetch page without CA certificate": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:    []Option{WithClientCertificate(clientCert, clientKey)},
+				wantErr: &tls.CertificateVerificationError{},
+			}
+		},
+		"Failed fetch page with other pinned public key": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts: []Option{
+					WithCACertificates(caFile),
+					WithClientCertificate(clientCert, clientKey),
+					WithCertificatePins("sha256//" + base64.StdEncoding.EncodeToString(otherPin[:])),
+				},
+				wantErr: &CertificatePinError{Subject: server.Certificate().Subject.String()},
+			}
+		},
+		"Failed fetch page without client certificate": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:    []Option{WithCACertificates(caFile), WithResponseCheck(CheckStatus)},
+				wantErr: &StatusError{StatusCode: http.StatusUnauthorized},
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			c, err := New(tt.opts...)
+			require.NoError(t, err)
+
+			page, err := c.Fetch(context.Background(), server.URL)
+
+			switch want := tt.wantErr.(type) {
+			case nil:
+				require.NoError(t, err)
+				assert.Equal(t, []byte("page"), page.Body)
+				require.NotNil(t, page.TLS)
+				assert.Equal(t, tt.wantVerified, page.TLS.Verified)
+				require.Len(t, page.TLS.Certificates, 1)
+				assert.Equal(t, server.Certificate().NotAfter.UTC(), page.TLS.Certificates[0].NotAfter)
+				assert.Equal(t, []string{"127.0.0.1", "::1"}, page.TLS.Certificates[0].IPAddresses)
+			case *tls.CertificateVerificationError:
+				var verificationErr *tls.CertificateVerificationError
+
+				assert.True(t, errors.As(err, &verificationErr), err)
+			default:
+				assert.ErrorContains(t, err, want.Error())
+			}
+		})
+	}
+}
+
+// issueCertificate returns a CA certificate and its key, or a certificate of 127.0.0.1 issued by the CA if given.
+func issueCertificate(t *testing.T, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	if ca == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		ca, caKey = template, key
+	} else {
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return certificate, key
+}
+
+func TestFetchTLSPinnedChain(t *testing.T) {
+	dir := t.TempDir()
+
+	ca, caKey := issueCertificate(t, "ca", nil, nil)
+	leaf, leafKey := issueCertificate(t, "server", ca, caKey)
+	// The pinned certificate is appended by the server, it isn't part of the verified chain.
+	pinned, _ := issueCertificate(t, "pinned", nil, nil)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("page"))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{leaf.Raw, pinned.Raw}, PrivateKey: leafKey}},
+		MinVersion:   tls.VersionTLS12,
+	}
+	server.StartTLS()
+
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0o600))
+
+	pin := func(certificate *x509.Certificate) string {
+		hash := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+
+		return "sha256//" + base64.StdEncoding.EncodeToString(hash[:])
+	}
+
+	type test struct {
+		opts    []Option
+		wantErr error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully fetch page with pinned CA of the verified chain": func(t *testing.T) test {
+			t.Helper()
+
+			return test{opts: []Option{WithCACertificates(caFile), WithCertificatePins(pin(ca))}}
+		},
+		"Successfully fetch page with pinned server certificate without verification": func(t *testing.T) test {
+			t.Helper()
+
+			return test{opts: []Option{WithInsecureSkipVerify(), WithCertificatePins(pin(leaf))}}
+		},
+		"Failed fetch page with pinned certificate appended to the chain": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:    []Option{WithCACertificates(caFile), WithCertificatePins(pin(pinned))},
+				wantErr: &CertificatePinError{Subject: leaf.Subject.String()},
+			}
+		},
+		"Failed fetch page with pinned certificate appended to the chain without verification": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				opts:    []Option{WithInsecureSkipVerify(), WithCertificatePins(pin(pinned))},
+				wantErr: &CertificatePinError{Subject: leaf.Subject.String()},
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			c, err := New(tt.opts...)
+			require.NoError(t, err)
+
+			page, err := c.Fetch(context.Background(), server.URL)
+			if tt.wantErr != nil {
+				assert.ErrorContains(t, err, tt.wantErr.Error())
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, []byte("page"), page.Body)
+		})
+	}
+}
+
+func TestWithCertificatePins(t *testing.T) {
+	pin := sha256.Sum256([]byte("key"))
+	// The base64 hash of this pin starts with `/`.
+	slashPin := bytes.Repeat([]byte{0xff}, sha256.Size)
+
+	type args struct {
+		value []string
+	}
+
+	type test struct {
+		args    args
+		want    int
+		wantErr error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully set certificate pins value": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{
+					value: []string{"sha256//" + base64.StdEncoding.EncodeToString(pin[:])},
+				},
+				want:    1,
+				wantErr: nil,
+			}
+		},
+		"Successfully set certificate pins value starting with a slash": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{
+					value: []string{"sha256//" + base64.StdEncoding.EncodeToString(slashPin)},
+				},
+				want:    1,
+				wantErr: nil,
+			}
+		},
+		"Failed set certificate pins value": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{
+					value: []string{"sha256//" + base64.StdEncoding.EncodeToString([]byte("short"))},
+				},
+				want:    0,
+				wantErr: errFailedSetCertificatePins,
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			tp := &client{}
+
+			err := WithCertificatePins(tt.args.value...)(tp)
+
+			assert.Len(t, tp.transport.tlsSettings().pins, tt.want)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestWithMinTLSVersion(t *testing.T) {
+	type args struct {
+		value string
+	}
+
+	type test struct {
+		args    args
+		want    uint16
+		wantErr error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully set min TLS version value": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{
+					value: "1.3",
+				},
+				want:    tls.VersionTLS13,
+				wantErr: nil,
+			}
+		},
+		"Failed set min TLS version value": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{
+					value: "1.4",
+				},
+				want:    0,
+				wantErr: errFailedSetMinTLSVersion,
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			tp := &client{}
+
+			err := WithMinTLSVersion(tt.args.value)(tp)
+
+			assert.Equal(t, tt.want, tp.transport.tlsSettings().minVersion)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
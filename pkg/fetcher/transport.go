@@ -0,0 +1,109 @@
+package fetcher
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// transportConfig configures the connections of the HTTP client built by New.
+type transportConfig struct {
+	// connectTimeout is the maximum duration of establishing a connection, 0 doesn't limit it.
+	connectTimeout time.Duration
+	// guard refuses the connections to the private addresses, nil allows every address.
+	guard *addressGuard
+	// proxy selects the proxy of every request, nil uses the proxy of the environment.
+	proxy *proxySelector
+	// tls configures the TLS connections, nil uses the default TLS config.
+	tls *tlsSettings
+}
+
+// isZero reports whether the config has no setting, so the default HTTP client is used.
+func (t transportConfig) isZero() bool {
+	return t == transportConfig{}
+}
+
+// roundTripper returns the transport of the HTTP client, the default transport with the settings.
+func (t transportConfig) roundTripper() http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = t.dialContext()
+
+	if t.tls != nil {
+		transport.TLSClientConfig = t.tls.config()
+	}
+
+	switch {
+	case t.proxy != nil:
+		transport.Proxy = t.proxyFunc()
+	case t.guard != nil:
+		// A proxy of the environment would connect to the denied addresses on behalf of the guard.
+		transport.Proxy = nil
+	}
+
+	return transport
+}
+
+// tlsSettings returns the TLS settings, created by the first TLS option.
+func (t *transportConfig) tlsSettings() *tlsSettings {
+	if t.tls == nil {
+		t.tls = &tlsSettings{}
+	}
+
+	return t.tls
+}
+
+// dialer returns the dialer of the connections.
+func (t transportConfig) dialer() *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout:   t.connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	// The address is checked once resolved, so every redirect hop and every IP of a host is checked.
+	if t.guard != nil {
+		dialer.Control = t.guard.control
+	}
+
+	return dialer
+}
+
+// dialContext returns the DialContext function of the transport.
+func (t transportConfig) dialContext() func(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := t.dialer()
+
+	if t.guard == nil || t.proxy == nil {
+		return dialer.DialContext
+	}
+
+	// The configured proxies are trusted, e.g. a proxy of the private network,
+	// the hosts proxied through them are checked by proxyFunc instead.
+	proxyDialer := *dialer
+	proxyDialer.Control = nil
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if t.proxy.addresses[address] {
+			return proxyDialer.DialContext(ctx, network, address)
+		}
+
+		return dialer.DialContext(ctx, network, address)
+	}
+}
+
+// proxyFunc returns the Proxy function of the transport.
+func (t transportConfig) proxyFunc() func(req *http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := t.proxy.proxy(req.URL)
+		if err != nil || proxyURL == nil || t.guard == nil {
+			return proxyURL, err
+		}
+
+		// The proxy connects to the host on behalf of the client, so the host is resolved and checked before it's proxied.
+		if err := t.guard.checkHost(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+
+		return proxyURL, nil
+	}
+}
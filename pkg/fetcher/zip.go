@@ -2,133 +2,312 @@ package fetcher
 
 import (
 	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
-	"os"
-	"path/filepath"
-	"sync"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/storage"
 )
 
+// errUnsafeEntryPath represents an error message when a zip entry path is absolute, not normalized or escapes the archive root.
+var errUnsafeEntryPath = errors.New("unsafe zip entry path")
+
+// zipEntry is a file in the storage to be added to the archive with the given entry name.
+type zipEntry struct {
+	name string
+	path string
+}
+
+// compressedEntry is a zip entry whose content is already compressed and ready to be written raw.
+type compressedEntry struct {
+	header *zip.FileHeader
+	data   []byte
+	err    error
+}
+
 // Zip zips the given `filePaths` and `dirs` into a single archive file specified by `filename`.
-func (c *client) Zip(filename string, filePaths, dirs []string) error {
+// The entries are named after their storage names, so extracting the archive
+// reproduces the storage layout and the relative links between the files keep working.
+//
+// The entries are compressed in parallel by a bounded number of workers,
+// while a single writer appends them to the archive in a deterministic order.
+// The partial archive is removed if zipping fails or the context is canceled.
+func (c *client) Zip(ctx context.Context, filename string, filePaths, dirs []string) error {
+	entries, err := c.collectEntries(".", filePaths, dirs)
+	if err != nil {
+		return err
+	}
+
 	// Create the zip file.
-	zipFile, err := os.Create(filename)
+	zipFile, err := c.storage.Create(filename)
 	if err != nil {
 		return fmt.Errorf("failed to create zip: %w", err)
 	}
 
-	defer func() { _ = zipFile.Close() }()
-
 	// Create a new zip writer.
 	zipWriter := zip.NewWriter(zipFile)
 
-	defer func() { _ = zipWriter.Close() }()
+	err = c.writeEntries(ctx, zipWriter, entries)
+	if err == nil {
+		err = zipWriter.Close()
+		if err != nil {
+			err = fmt.Errorf("failed to close zip writer: %w", err)
+		}
+	}
 
-	// Channel to wait for all the goroutines to complete.
-	var wg sync.WaitGroup
+	if err != nil {
+		// The partial archive is committed by closing the file, so it's removed afterwards.
+		_ = zipFile.Close()
+		_ = c.storage.Remove(filename)
+
+		return err
+	}
 
-	c.errChan = make(chan error, len(filePaths)+len(dirs))
+	if err := zipFile.Close(); err != nil {
+		return fmt.Errorf("failed to close zip: %w", err)
+	}
+
+	return nil
+}
+
+// collectEntries lists the files and the files inside the dirs to be added to the archive.
+func (c *client) collectEntries(baseDir string, filePaths, dirs []string) ([]zipEntry, error) {
+	var entries []zipEntry
+
+	seen := make(map[string]bool)
+
+	add := func(filePath string) error {
+		name, err := EntryName(baseDir, filePath)
+		if err != nil {
+			return err
+		}
+
+		// Skips the file if it's already added, e.g. a file inside one of the dirs.
+		if seen[name] {
+			return nil
+		}
+
+		seen[name] = true
+
+		entries = append(entries, zipEntry{name: name, path: filePath})
+
+		return nil
+	}
 
-	// Zip the file with concurrency.
 	for _, filePath := range filePaths {
-		wg.Add(1)
+		if err := add(filePath); err != nil {
+			return nil, fmt.Errorf("failed to add file to zip: %w", err)
+		}
+	}
 
-		go func(filePath string) {
-			defer wg.Done()
+	for _, dir := range dirs {
+		// List all the files in the directory.
+		names, err := c.storage.List(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list dir to zip: %w", err)
+		}
 
-			if err := c.addFile(zipWriter, filePath); err != nil {
-				c.errChan <- fmt.Errorf("failed to add file to zip: %w", err)
-				return
+		for _, name := range names {
+			if err := add(name); err != nil {
+				return nil, fmt.Errorf("failed to add dir to zip: %w", err)
 			}
-		}(filePath)
+		}
 	}
 
-	// Zip the dir with concurrency.
-	for _, dir := range dirs {
-		wg.Add(1)
+	return entries, nil
+}
 
-		go func(dir string) {
-			defer wg.Done()
+// writeEntries compresses the entries with `c.zipConcurrency` workers and appends them to the zip writer in order.
+// At most `c.zipConcurrency` compressed entries are held in memory at the same time.
+// It stops before the next entry once the context is canceled.
+func (c *client) writeEntries(ctx context.Context, zipWriter *zip.Writer, entries []zipEntry) error {
+	concurrency := c.zipConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]chan compressedEntry, len(entries))
+	for i := range results {
+		results[i] = make(chan compressedEntry, 1)
+	}
 
-			if err := c.addDir(zipWriter, dir); err != nil {
-				c.errChan <- fmt.Errorf("failed to add dir to zip: %w", err)
+	// Semaphore to bound the number of entries being compressed or waiting to be written.
+	sem := make(chan struct{}, concurrency)
+
+	// Stops scheduling the compression if the writer returns early.
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for i, entry := range entries {
+			select {
+			case sem <- struct{}{}:
+			case <-done:
+				return
+			case <-ctx.Done():
 				return
 			}
-		}(dir)
-	}
 
-	// Wait for all the goroutines to complete.
-	wg.Wait()
+			go func(i int, entry zipEntry) {
+				results[i] <- c.compressEntry(entry)
+			}(i, entry)
+		}
+	}()
 
-	// Check the error channels for any errors.
-	select {
-	case err := <-c.errChan:
-		return err
-	default:
-		close(c.errChan)
-		return nil
+	for i := range entries {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("failed to zip: %w", err)
+		}
+
+		var result compressedEntry
+
+		// The entry may never be scheduled once the context is canceled.
+		select {
+		case result = <-results[i]:
+		case <-ctx.Done():
+			return fmt.Errorf("failed to zip: %w", ctx.Err())
+		}
+
+		if result.err != nil {
+			return result.err
+		}
+
+		fileWriter, err := zipWriter.CreateRaw(result.header)
+		if err != nil {
+			return fmt.Errorf("failed create file to zip: %w", err)
+		}
+
+		if _, err := fileWriter.Write(result.data); err != nil {
+			return fmt.Errorf("failed copy file to zip: %w", err)
+		}
+
+		<-sem
 	}
+
+	return nil
 }
 
-// addFile adds filepath to zip.
-func (c *client) addFile(zipWriter *zip.Writer, filePath string) error {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+// compressEntry reads the entry file and deflates it into memory, filling the header checksum and sizes.
+func (c *client) compressEntry(entry zipEntry) compressedEntry {
+	// Create a new file header for the current file.
+	header := &zip.FileHeader{
+		Name:     entry.name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	}
+
+	header.SetMode(0644)
 
-	file, err := os.Open(filePath)
+	file, err := c.storage.Open(entry.path)
 	if err != nil {
-		return fmt.Errorf("failed open file to zip: %w", err)
+		return compressedEntry{err: fmt.Errorf("failed open file to zip: %w", err)}
 	}
 
-	fileWriter, err := zipWriter.Create(filePath)
+	defer func() { _ = file.Close() }()
+
+	var buf bytes.Buffer
+
+	flateWriter, err := flate.NewWriter(&buf, flate.DefaultCompression)
 	if err != nil {
-		return fmt.Errorf("failed create file to zip: %w", err)
+		return compressedEntry{err: fmt.Errorf("failed create compressor: %w", err)}
 	}
 
-	if _, err := io.Copy(fileWriter, file); err != nil {
-		return fmt.Errorf("failed copy file to zip: %w", err)
+	hash := crc32.NewIEEE()
+
+	size, err := io.Copy(io.MultiWriter(flateWriter, hash), file)
+	if err != nil {
+		return compressedEntry{err: fmt.Errorf("failed compress file to zip: %w", err)}
 	}
 
-	return nil
+	if err := flateWriter.Close(); err != nil {
+		return compressedEntry{err: fmt.Errorf("failed compress file to zip: %w", err)}
+	}
+
+	header.CRC32 = hash.Sum32()
+	header.UncompressedSize64 = uint64(size)
+	header.CompressedSize64 = uint64(buf.Len())
+
+	return compressedEntry{header: header, data: buf.Bytes()}
 }
 
-// addDir adds directory to zip.
-func (c *client) addDir(zipWriter *zip.Writer, dir string) error {
-	// Walk through all the files in the directory.
-	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error { //nolint:staticcheck
-		if info.IsDir() {
-			return nil
-		}
+// EntryName converts the storage file path into a zip entry name relative to `baseDir`.
+// The result always uses forward slashes and never points outside of `baseDir`.
+func EntryName(baseDir, filePath string) (string, error) {
+	base, err := storage.CleanName(baseDir)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", errUnsafeEntryPath, baseDir, err)
+	}
 
-		// Create a new file header for the current file.
-		header, err := zip.FileInfoHeader(info) //nolint:staticcheck
-		if err != nil {
-			return fmt.Errorf("failed get file header info: %w", err)
+	name, err := storage.CleanName(filePath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", errUnsafeEntryPath, filePath, err)
+	}
+
+	if base != "." {
+		if !strings.HasPrefix(name, base+"/") {
+			return "", fmt.Errorf("%w: %s: outside of %s", errUnsafeEntryPath, filePath, baseDir)
 		}
 
-		// Set the name of the file in the zip archive.
-		header.Name = path
+		name = strings.TrimPrefix(name, base+"/")
+	}
 
-		// Create a new file in the zip archive.
-		targetFile, err := zipWriter.CreateHeader(header)
-		if err != nil {
-			return fmt.Errorf("failed create file header: %w", err)
+	if err := ValidateEntryName(name); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// ValidateEntryName checks that the zip entry name is relative, normalized and traversal-free,
+// so extracting it can't write outside of the target directory (zip-slip).
+func ValidateEntryName(name string) error {
+	switch {
+	case name == "" || name == ".":
+		return fmt.Errorf("%w: empty name", errUnsafeEntryPath)
+	case strings.Contains(name, "\\"):
+		return fmt.Errorf("%w: %s: contains backslash", errUnsafeEntryPath, name)
+	case strings.HasPrefix(name, "/") || hasVolumeName(name):
+		return fmt.Errorf("%w: %s: absolute path", errUnsafeEntryPath, name)
+	case path.Clean(name) != name:
+		return fmt.Errorf("%w: %s: not normalized", errUnsafeEntryPath, name)
+	case name == ".." || strings.HasPrefix(name, "../"):
+		return fmt.Errorf("%w: %s: escapes archive root", errUnsafeEntryPath, name)
+	}
+
+	return nil
+}
+
+// VerifyArchive checks every entry of the zip archive with ValidateEntryName
+// and rejects duplicated entries, it should be called before reading the archive back.
+func VerifyArchive(reader *zip.Reader) error {
+	seen := make(map[string]bool, len(reader.File))
+
+	for _, file := range reader.File {
+		name := strings.TrimSuffix(file.Name, "/")
+
+		if err := ValidateEntryName(name); err != nil {
+			return err
 		}
 
-		// Open the current file.
-		file, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("failed open file from dir to zip: %w", err)
+		if seen[name] {
+			return fmt.Errorf("%w: %s: duplicated entry", errUnsafeEntryPath, name)
 		}
 
-		defer func() { _ = file.Close() }()
+		seen[name] = true
+	}
 
-		// Copy the contents of the current file to the new file in the zip archive.
-		_, err = io.Copy(targetFile, file)
-		if err != nil {
-			return fmt.Errorf("failed copy file from dir to zip: %w", err)
-		}
+	return nil
+}
 
-		return nil
-	})
+// hasVolumeName reports whether the name starts with a Windows drive letter, e.g. `C:`.
+func hasVolumeName(name string) bool {
+	return len(name) >= 2 && name[1] == ':' &&
+		(('a' <= name[0] && name[0] <= 'z') || ('A' <= name[0] && name[0] <= 'Z'))
 }
@@ -0,0 +1,231 @@
+package fetcher
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/moemoe89/fetch/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEntryName(t *testing.T) {
+	type args struct {
+		name string
+	}
+
+	type test struct {
+		args    args
+		wantErr error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Valid relative entry name": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:    args{name: "example.com/style.css"},
+				wantErr: nil,
+			}
+		},
+		"Invalid absolute entry name": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:    args{name: "/etc/passwd"},
+				wantErr: errUnsafeEntryPath,
+			}
+		},
+		"Invalid windows absolute entry name": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:    args{name: "C:/windows/win.ini"},
+				wantErr: errUnsafeEntryPath,
+			}
+		},
+		"Invalid traversal entry name": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:    args{name: "../../etc/passwd"},
+				wantErr: errUnsafeEntryPath,
+			}
+		},
+		"Invalid not normalized entry name": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:    args{name: "example.com/../../passwd"},
+				wantErr: errUnsafeEntryPath,
+			}
+		},
+		"Invalid backslash entry name": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:    args{name: `..\passwd`},
+				wantErr: errUnsafeEntryPath,
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			err := ValidateEntryName(tt.args.name)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestZip(t *testing.T) {
+	type args struct {
+		filename  string
+		filePaths []string
+		dirs      []string
+	}
+
+	type test struct {
+		args      args
+		ctx       context.Context
+		want      []string
+		wantErr   error
+		beforeRun func(s storage.Storage)
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully zip files and dirs with relative entry names": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{
+					filename:  "out/example.com.zip",
+					filePaths: []string{"out/example.com.html"},
+					dirs:      []string{"out/example.com"},
+				},
+				want: []string{
+					"out/example.com.html",
+					"out/example.com/_metadata.json",
+					"out/example.com/app.js",
+					"out/example.com/logo.png",
+					"out/example.com/style.css",
+				},
+				beforeRun: func(s storage.Storage) {
+					require.NoError(t, s.WriteFile("out/example.com.html", []byte("<html></html>")))
+					require.NoError(t, s.WriteFile("out/example.com/_metadata.json", []byte("{}")))
+					require.NoError(t, s.WriteFile("out/example.com/style.css", []byte("body{}")))
+					require.NoError(t, s.WriteFile("out/example.com/app.js", []byte("alert(1)")))
+					require.NoError(t, s.WriteFile("out/example.com/logo.png", []byte("png")))
+				},
+			}
+		},
+		"Failed zip with canceled context": func(t *testing.T) test {
+			t.Helper()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			return test{
+				args: args{
+					filename:  "out/example.com.zip",
+					filePaths: []string{"out/example.com.html"},
+				},
+				ctx:     ctx,
+				wantErr: context.Canceled,
+				beforeRun: func(s storage.Storage) {
+					require.NoError(t, s.WriteFile("out/example.com.html", []byte("<html></html>")))
+				},
+			}
+		},
+		"Failed zip absolute file": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{
+					filename:  "out/example.com.zip",
+					filePaths: []string{"/etc/passwd"},
+				},
+				wantErr: errUnsafeEntryPath,
+			}
+		},
+		"Failed zip file escaping the storage root": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{
+					filename:  "example.com.zip",
+					filePaths: []string{"../example.com.html"},
+				},
+				wantErr: errUnsafeEntryPath,
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			s := storage.NewMemory()
+
+			if tt.beforeRun != nil {
+				tt.beforeRun(s)
+			}
+
+			c := &client{storage: s, zipConcurrency: 4}
+
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			err := c.Zip(ctx, tt.args.filename, tt.args.filePaths, tt.args.dirs)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+
+				// The partial archive isn't left in the storage.
+				_, err = s.Stat(tt.args.filename)
+				assert.ErrorIs(t, err, storage.ErrNotExist)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			body, err := s.ReadFile(tt.args.filename)
+			require.NoError(t, err)
+
+			reader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+			require.NoError(t, err)
+
+			assert.NoError(t, VerifyArchive(reader))
+
+			var got []string
+			for _, file := range reader.File {
+				got = append(got, file.Name)
+
+				// Checks the raw compressed entry can be read back with a valid checksum.
+				rc, err := file.Open()
+				require.NoError(t, err)
+
+				_, err = io.Copy(io.Discard, rc)
+				assert.NoError(t, err)
+
+				_ = rc.Close()
+			}
+
+			sort.Strings(got)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
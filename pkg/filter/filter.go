@@ -0,0 +1,395 @@
+// Package filter selects the pages and the assets to fetch with include and exclude rules
+// on their URL, host, MIME type and size.
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrSkipped is returned when a page or an asset is skipped by the rules.
+	ErrSkipped = errors.New("skipped by filter")
+	// errInvalidRule represents an error message when a rule can't be compiled.
+	errInvalidRule = errors.New("invalid filter rule")
+	// errInvalidSize represents an error message when the size can't be parsed.
+	errInvalidSize = errors.New("invalid size")
+)
+
+// sizeUnits are the units of the sizes, e.g. `10MB`.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// Config are the rules of the filter, e.g. read from a JSON config file.
+// The zero value selects everything.
+type Config struct {
+	// Include selects only the URLs matching any of the glob patterns, `*` matches any characters.
+	Include []string `json:"include,omitempty"`
+	// Exclude skips the URLs matching any of the glob patterns.
+	Exclude []string `json:"exclude,omitempty"`
+	// IncludeRegex selects only the URLs matching any of the regular expressions.
+	IncludeRegex []string `json:"include_regex,omitempty"`
+	// ExcludeRegex skips the URLs matching any of the regular expressions.
+	ExcludeRegex []string `json:"exclude_regex,omitempty"`
+	// AllowHosts selects only the URLs of the hosts, `*.example.com` matches the subdomains of example.com.
+	AllowHosts []string `json:"allow_hosts,omitempty"`
+	// DenyHosts skips the URLs of the hosts.
+	DenyHosts []string `json:"deny_hosts,omitempty"`
+	// MIMETypes selects only the responses of the MIME types, `image/*` matches every image.
+	MIMETypes []string `json:"mime_types,omitempty"`
+	// DenyMIMETypes skips the responses of the MIME types.
+	DenyMIMETypes []string `json:"deny_mime_types,omitempty"`
+	// MaxAssetSize skips the assets larger than the size, e.g. `10MB`, empty doesn't limit the size.
+	MaxAssetSize string `json:"max_asset_size,omitempty"`
+}
+
+// pattern is a compiled glob pattern or regular expression of a URL rule.
+type pattern struct {
+	// source is the pattern as written in the rule.
+	source string
+	re     *regexp.Regexp
+}
+
+// Filter applies the rules of a Config. A nil Filter selects everything.
+type Filter struct {
+	include       []pattern
+	exclude       []pattern
+	allowHosts    []string
+	denyHosts     []string
+	mimeTypes     []string
+	denyMIMETypes []string
+	maxAssetSize  int64
+}
+
+// LoadConfig reads the rules from the JSON config file.
+func LoadConfig(filename string) (Config, error) {
+	var config Config
+
+	body, err := os.ReadFile(filename)
+	if err != nil {
+		return config, fmt.Errorf("failed to read filter config: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&config); err != nil {
+		return config, fmt.Errorf("failed to unmarshal filter config: %s: %w", filename, err)
+	}
+
+	return config, nil
+}
+
+// Merge returns the config with the rules of both configs, e.g. the config file and the flags.
+// The max asset size of `other` is used if it's set.
+func (c Config) Merge(other Config) Config {
+	merged := Config{
+		Include:       append(append([]string(nil), c.Include...), other.Include...),
+		Exclude:       append(append([]string(nil), c.Exclude...), other.Exclude...),
+		IncludeRegex:  append(append([]string(nil), c.IncludeRegex...), other.IncludeRegex...),
+		ExcludeRegex:  append(append([]string(nil), c.ExcludeRegex...), other.ExcludeRegex...),
+		AllowHosts:    append(append([]string(nil), c.AllowHosts...), other.AllowHosts...),
+		DenyHosts:     append(append([]string(nil), c.DenyHosts...), other.DenyHosts...),
+		MIMETypes:     append(append([]string(nil), c.MIMETypes...), other.MIMETypes...),
+		DenyMIMETypes: append(append([]string(nil), c.DenyMIMETypes...), other.DenyMIMETypes...),
+		MaxAssetSize:  c.MaxAssetSize,
+	}
+
+	if other.MaxAssetSize != "" {
+		merged.MaxAssetSize = other.MaxAssetSize
+	}
+
+	return merged
+}
+
+// IsZero reports whether the config has no rule.
+func (c Config) IsZero() bool {
+	return len(c.Include)+len(c.Exclude)+len(c.IncludeRegex)+len(c.ExcludeRegex)+
+		len(c.AllowHosts)+len(c.DenyHosts)+len(c.MIMETypes)+len(c.DenyMIMETypes) == 0 && c.MaxAssetSize == ""
+}
+
+// New compiles the rules of the config.
+func New(config Config) (*Filter, error) {
+	f := &Filter{
+		allowHosts:    lower(config.AllowHosts),
+		denyHosts:     lower(config.DenyHosts),
+		mimeTypes:     lower(config.MIMETypes),
+		denyMIMETypes: lower(config.DenyMIMETypes),
+	}
+
+	for _, glob := range config.Include {
+		f.include = append(f.include, pattern{source: glob, re: compileGlob(glob)})
+	}
+
+	for _, glob := range config.Exclude {
+		f.exclude = append(f.exclude, pattern{source: glob, re: compileGlob(glob)})
+	}
+
+	for _, expr := range config.IncludeRegex {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: include regex %q: %v", errInvalidRule, expr, err)
+		}
+
+		f.include = append(f.include, pattern{source: expr, re: re})
+	}
+
+	for _, expr := range config.ExcludeRegex {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: exclude regex %q: %v", errInvalidRule, expr, err)
+		}
+
+		f.exclude = append(f.exclude, pattern{source: expr, re: re})
+	}
+
+	if config.MaxAssetSize != "" {
+		size, err := ParseSize(config.MaxAssetSize)
+		if err != nil {
+			return nil, fmt.Errorf("%w: max asset size: %v", errInvalidRule, err)
+		}
+
+		f.maxAssetSize = size
+	}
+
+	return f, nil
+}
+
+// URL returns an error wrapping ErrSkipped with the reason if the rules skip the URL.
+func (f *Filter) URL(rawURL string) error {
+	if f == nil {
+		return nil
+	}
+
+	for _, exclude := range f.exclude {
+		if exclude.re.MatchString(rawURL) {
+			return fmt.Errorf("%w: URL matches exclude rule %s", ErrSkipped, exclude.source)
+		}
+	}
+
+	if len(f.include) > 0 && !matchAny(f.include, rawURL) {
+		return fmt.Errorf("%w: URL doesn't match any include rule", ErrSkipped)
+	}
+
+	if len(f.allowHosts) == 0 && len(f.denyHosts) == 0 {
+		return nil
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: invalid URL: %v", ErrSkipped, err)
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+
+	for _, deny := range f.denyHosts {
+		if matchHost(deny, host) {
+			return fmt.Errorf("%w: host %s is denied", ErrSkipped, host)
+		}
+	}
+
+	if len(f.allowHosts) > 0 && !matchAnyHost(f.allowHosts, host) {
+		return fmt.Errorf("%w: host %s isn't allowed", ErrSkipped, host)
+	}
+
+	return nil
+}
+
+// CheckPage returns an error wrapping ErrSkipped if the rules skip the page response, before its body is read.
+func (f *Filter) CheckPage(resp *http.Response) error {
+	if f == nil {
+		return nil
+	}
+
+	return f.checkMIMEType(resp.Header.Get("Content-Type"))
+}
+
+// CheckAsset returns an error wrapping ErrSkipped if the rules skip the asset response, before its body is read.
+func (f *Filter) CheckAsset(resp *http.Response) error {
+	if f == nil {
+		return nil
+	}
+
+	if err := f.checkMIMEType(resp.Header.Get("Content-Type")); err != nil {
+		return err
+	}
+
+	return f.AssetSize(resp.ContentLength)
+}
+
+// MaxAssetSize returns the max asset size in bytes, 0 doesn't limit the size.
+func (f *Filter) MaxAssetSize() int64 {
+	if f == nil {
+		return 0
+	}
+
+	return f.maxAssetSize
+}
+
+// AssetSize returns an error wrapping ErrSkipped if the asset is larger than the max asset size.
+// A negative size is unknown and never skipped.
+func (f *Filter) AssetSize(size int64) error {
+	if f == nil || f.maxAssetSize == 0 || size <= f.maxAssetSize {
+		return nil
+	}
+
+	return fmt.Errorf("%w: size %d is larger than %d bytes", ErrSkipped, size, f.maxAssetSize)
+}
+
+// checkMIMEType checks the media type of the Content-Type header, a response without it is never skipped.
+func (f *Filter) checkMIMEType(contentType string) error {
+	if contentType == "" || (len(f.mimeTypes) == 0 && len(f.denyMIMETypes) == 0) {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+
+	for _, deny := range f.denyMIMETypes {
+		if matchMIMEType(deny, mediaType) {
+			return fmt.Errorf("%w: MIME type %s is denied", ErrSkipped, mediaType)
+		}
+	}
+
+	if len(f.mimeTypes) == 0 {
+		return nil
+	}
+
+	for _, allow := range f.mimeTypes {
+		if matchMIMEType(allow, mediaType) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: MIME type %s isn't allowed", ErrSkipped, mediaType)
+}
+
+// Reason returns the reason of an error wrapping ErrSkipped, without the messages of the errors wrapping it.
+func Reason(err error) string {
+	message := err.Error()
+
+	if i := strings.Index(message, ErrSkipped.Error()); i >= 0 {
+		return message[i:]
+	}
+
+	return message
+}
+
+// ParseSize parses a size in bytes with an optional KB, MB or GB unit of 1024 multiples, e.g. `10MB`.
+func ParseSize(value string) (int64, error) {
+	trimmed := strings.ToUpper(strings.TrimSpace(value))
+
+	multiplier := int64(1)
+
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(trimmed, unit.suffix) {
+			trimmed = strings.TrimSpace(strings.TrimSuffix(trimmed, unit.suffix))
+			multiplier = unit.bytes
+
+			break
+		}
+	}
+
+	size, err := strconv.ParseInt(trimmed, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("%w: %s", errInvalidSize, value)
+	}
+
+	// The size in bytes must fit in an int64, a larger one would wrap around.
+	if size > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("%w: %s is too large", errInvalidSize, value)
+	}
+
+	return size * multiplier, nil
+}
+
+// compileGlob compiles the glob pattern matching the whole URL, `*` matches any characters and `?` one character.
+func compileGlob(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+
+	expr.WriteString("^")
+
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	expr.WriteString("$")
+
+	return regexp.MustCompile(expr.String())
+}
+
+// matchAny reports whether the value matches any of the patterns.
+func matchAny(patterns []pattern, value string) bool {
+	for _, p := range patterns {
+		if p.re.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAnyHost reports whether the host matches any of the host patterns.
+func matchAnyHost(patterns []string, host string) bool {
+	for _, allow := range patterns {
+		if matchHost(allow, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchHost reports whether the host is the pattern, or a subdomain of `*.domain` patterns.
+func matchHost(pattern, host string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+
+	return host == pattern
+}
+
+// matchMIMEType reports whether the media type is the pattern, or of the `type/*` pattern.
+func matchMIMEType(pattern, mediaType string) bool {
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+	}
+
+	return mediaType == pattern
+}
+
+// lower returns the values in lower case.
+func lower(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(strings.TrimSpace(value))
+	}
+
+	return lowered
+}
@@ -0,0 +1,163 @@
+package filter
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterURL(t *testing.T) {
+	type test struct {
+		config Config
+		url    string
+		want   bool
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully select URL without rules": func(t *testing.T) test {
+			t.Helper()
+
+			return test{url: "https://example.com/video.mp4", want: true}
+		},
+		"Successfully skip URL matching exclude glob": func(t *testing.T) test {
+			t.Helper()
+
+			return test{config: Config{Exclude: []string{"*.mp4"}}, url: "https://example.com/video.mp4"}
+		},
+		"Successfully skip URL matching exclude regex": func(t *testing.T) test {
+			t.Helper()
+
+			return test{config: Config{ExcludeRegex: []string{`/private/`}}, url: "https://example.com/private/a"}
+		},
+		"Successfully select URL matching include glob": func(t *testing.T) test {
+			t.Helper()
+
+			return test{config: Config{Include: []string{"https://example.com/docs/*"}}, url: "https://example.com/docs/a/b", want: true}
+		},
+		"Successfully skip URL not matching include rules": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				config: Config{Include: []string{"https://example.com/docs/*"}, IncludeRegex: []string{`\.css$`}},
+				url:    "https://example.com/blog/a",
+			}
+		},
+		"Successfully skip denied host": func(t *testing.T) test {
+			t.Helper()
+
+			return test{config: Config{DenyHosts: []string{"*.tracker.com"}}, url: "https://cdn.tracker.com/t.js"}
+		},
+		"Successfully skip host not allowed": func(t *testing.T) test {
+			t.Helper()
+
+			return test{config: Config{AllowHosts: []string{"example.com"}}, url: "https://cdn.example.org/a.js"}
+		},
+		"Successfully select allowed host with port": func(t *testing.T) test {
+			t.Helper()
+
+			return test{config: Config{AllowHosts: []string{"Example.com"}}, url: "https://example.com:8443/", want: true}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			f, err := New(tt.config)
+			require.NoError(t, err)
+
+			err = f.URL(tt.url)
+			if tt.want {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrSkipped)
+		})
+	}
+}
+
+func TestFilterResponse(t *testing.T) {
+	f, err := New(Config{MIMETypes: []string{"text/html", "image/*", "text/css"}, DenyMIMETypes: []string{"image/gif"}, MaxAssetSize: "1KB"})
+	require.NoError(t, err)
+
+	response := func(contentType string, size int64) *http.Response {
+		return &http.Response{Header: http.Header{"Content-Type": {contentType}}, ContentLength: size}
+	}
+
+	assert.NoError(t, f.CheckPage(response("text/html; charset=utf-8", 4096)))
+	assert.ErrorIs(t, f.CheckPage(response("application/pdf", 10)), ErrSkipped)
+	assert.NoError(t, f.CheckAsset(response("image/png", 1024)))
+	assert.NoError(t, f.CheckAsset(response("", -1)))
+	assert.ErrorIs(t, f.CheckAsset(response("image/png", 1025)), ErrSkipped)
+	assert.ErrorIs(t, f.CheckAsset(response("image/gif", 10)), ErrSkipped)
+	assert.ErrorIs(t, f.CheckAsset(response("video/mp4", 10)), ErrSkipped)
+
+	var none *Filter
+
+	assert.NoError(t, none.URL("https://example.com"))
+	assert.NoError(t, none.CheckAsset(response("video/mp4", 1<<40)))
+}
+
+func TestReason(t *testing.T) {
+	f, err := New(Config{DenyHosts: []string{"tracker.com"}})
+	require.NoError(t, err)
+
+	err = fmt.Errorf("failed to fetch page: %w", f.URL("https://tracker.com/t.js"))
+	assert.Equal(t, "skipped by filter: host tracker.com is denied", Reason(err))
+}
+
+func TestNewInvalidRule(t *testing.T) {
+	_, err := New(Config{ExcludeRegex: []string{"("}})
+	assert.ErrorIs(t, err, errInvalidRule)
+
+	_, err = New(Config{MaxAssetSize: "ten"})
+	assert.ErrorIs(t, err, errInvalidRule)
+}
+
+func TestParseSize(t *testing.T) {
+	size, err := ParseSize("10MB")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10<<20), size)
+
+	size, err = ParseSize("512 kb")
+	require.NoError(t, err)
+	assert.Equal(t, int64(512<<10), size)
+
+	size, err = ParseSize("100")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), size)
+
+	_, err = ParseSize("-1B")
+	assert.ErrorIs(t, err, errInvalidSize)
+
+	size, err = ParseSize("8589934591GB")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8589934591<<30), size)
+
+	_, err = ParseSize("9000000000GB")
+	assert.ErrorIs(t, err, errInvalidSize)
+}
+
+func TestLoadConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "filter.json")
+
+	require.NoError(t, os.WriteFile(filename, []byte(`{"exclude": ["*.mp4"], "max_asset_size": "5MB"}`), 0644))
+
+	config, err := LoadConfig(filename)
+	require.NoError(t, err)
+
+	merged := config.Merge(Config{Exclude: []string{"*.webm"}, DenyHosts: []string{"tracker.com"}})
+	assert.Equal(t, Config{Exclude: []string{"*.mp4", "*.webm"}, DenyHosts: []string{"tracker.com"}, MaxAssetSize: "5MB"}, merged)
+
+	require.NoError(t, os.WriteFile(filename, []byte(`{"exclude_hosts": ["a.com"]}`), 0644))
+
+	_, err = LoadConfig(filename)
+	assert.Error(t, err)
+}
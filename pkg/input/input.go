@@ -0,0 +1,365 @@
+// Package input reads the lists of URLs to fetch, with the options of every URL, from a file or stdin.
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/moemoe89/fetch/pkg/storage"
+	"golang.org/x/net/http/httpguts"
+)
+
+// Input formats.
+const (
+	// FormatAuto detects the format from the content.
+	FormatAuto = ""
+	// FormatLines is one URL per line, empty lines and lines starting with `#` are ignored.
+	FormatLines = "lines"
+	// FormatCSV is a CSV file with a header row naming the columns, see Columns.
+	FormatCSV = "csv"
+	// FormatJSONLines is a JSON object per line with the fields of Item.
+	FormatJSONLines = "jsonl"
+)
+
+// CSV columns, only the url column is required.
+const (
+	ColumnURL     = "url"
+	ColumnOutput  = "output"
+	ColumnHeaders = "headers"
+	ColumnDepth   = "depth"
+)
+
+// headerSeparator separates the headers of the CSV headers column, e.g. `Authorization: Bearer x | Accept: text/html`.
+const headerSeparator = "|"
+
+var (
+	// errInvalidInput represents an error message when some items of the input are invalid.
+	errInvalidInput = errors.New("invalid input")
+	// errUnknownFormat represents an error message when the input format isn't supported.
+	errUnknownFormat = errors.New("unknown input format")
+	// errInvalidURL represents an error message when the URL isn't an absolute http or https URL.
+	errInvalidURL = errors.New("invalid URL")
+	// errInvalidOutput represents an error message when the output name isn't a relative path.
+	errInvalidOutput = errors.New("invalid output name")
+	// errInvalidHeader represents an error message when a header name or value isn't valid.
+	errInvalidHeader = errors.New("invalid header")
+	// errInvalidDepth represents an error message when the depth is negative.
+	errInvalidDepth = errors.New("invalid depth")
+)
+
+// Item is a URL to fetch with its options.
+type Item struct {
+	URL string `json:"url"`
+	// Output is the directory under the output directory where the URL is saved, empty saves it in the output directory.
+	Output string `json:"output,omitempty"`
+	// Headers are the HTTP headers sent when fetching the page.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Depth is the number of levels of links on the same host followed from the page, 0 fetches the page only.
+	Depth int `json:"depth,omitempty"`
+	// Line is the line of the item in the input, 0 if it doesn't come from an input file.
+	Line int `json:"-"`
+}
+
+// FormatFromName returns the format of the input file from its extension,
+// FormatAuto if the extension isn't known, e.g. for stdin.
+func FormatFromName(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".jsonl", ".ndjson":
+		return FormatJSONLines
+	case ".txt":
+		return FormatLines
+	default:
+		return FormatAuto
+	}
+}
+
+// Read reads the items of the input in the given format, FormatAuto detects it from the content.
+// The items aren't validated, see Validate.
+func Read(r io.Reader, format string) ([]Item, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	if format == FormatAuto {
+		format = detect(body)
+	}
+
+	switch format {
+	case FormatLines:
+		return readLines(body)
+	case FormatCSV:
+		return readCSV(body)
+	case FormatJSONLines:
+		return readJSONLines(body)
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownFormat, format)
+	}
+}
+
+// Validate checks every item and returns an error listing all invalid items, so they're fixed at once.
+func Validate(items []Item) error {
+	var invalid []string
+
+	for i, item := range items {
+		err := item.validate()
+		if err == nil {
+			continue
+		}
+
+		position := "item " + strconv.Itoa(i+1)
+		if item.Line > 0 {
+			position = "line " + strconv.Itoa(item.Line)
+		}
+
+		invalid = append(invalid, position+": "+err.Error())
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("%w:\n%s", errInvalidInput, strings.Join(invalid, "\n"))
+	}
+
+	return nil
+}
+
+// validate checks the URL and the options of the item.
+func (i Item) validate() error {
+	if err := ValidateURL(i.URL); err != nil {
+		return err
+	}
+
+	if i.Output != "" {
+		cleaned, err := storage.CleanName(i.Output)
+		if err != nil || cleaned == "." || strings.HasPrefix(cleaned, "_") {
+			return fmt.Errorf("%w: %q: expected a relative path not starting with _", errInvalidOutput, i.Output)
+		}
+	}
+
+	for name, value := range i.Headers {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("%w: name %q", errInvalidHeader, name)
+		}
+
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("%w: value of %s", errInvalidHeader, name)
+		}
+	}
+
+	if i.Depth < 0 {
+		return fmt.Errorf("%w: %d", errInvalidDepth, i.Depth)
+	}
+
+	return nil
+}
+
+// ValidateURL checks the URL is an absolute http or https URL.
+func ValidateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", errInvalidURL, rawURL, err)
+	}
+
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: %s: expected an absolute http or https URL", errInvalidURL, rawURL)
+	}
+
+	return nil
+}
+
+// detect returns the format of the input from its first significant line:
+// a JSON object for JSON lines, a header row with the url column for CSV, otherwise a URL per line.
+func detect(body []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "{") {
+			return FormatJSONLines
+		}
+
+		if strings.Contains(line, ",") {
+			header, err := csv.NewReader(strings.NewReader(line)).Read()
+			if err == nil && columnIndex(header, ColumnURL) >= 0 {
+				return FormatCSV
+			}
+		}
+
+		break
+	}
+
+	return FormatLines
+}
+
+// readLines reads a URL per line.
+func readLines(body []byte) ([]Item, error) {
+	var items []Item
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		items = append(items, Item{URL: text, Line: line})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	return items, nil
+}
+
+// readCSV reads a row per URL, the columns are named by the header row.
+func readCSV(body []byte) ([]Item, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for _, column := range []string{ColumnURL, ColumnOutput, ColumnHeaders, ColumnDepth} {
+		columns[column] = columnIndex(header, column)
+	}
+
+	if columns[ColumnURL] < 0 {
+		return nil, fmt.Errorf("%w: CSV header has no %s column", errInvalidInput, ColumnURL)
+	}
+
+	var items []Item
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		field := func(column string) string {
+			if i := columns[column]; i >= 0 && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+
+			return ""
+		}
+
+		item := Item{URL: field(ColumnURL), Output: field(ColumnOutput), Line: line}
+
+		if item.URL == "" {
+			continue
+		}
+
+		item.Headers, err = parseHeaders(field(ColumnHeaders))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if depth := field(ColumnDepth); depth != "" {
+			item.Depth, err = strconv.Atoi(depth)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w: %q", line, errInvalidDepth, depth)
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// readJSONLines reads a JSON object per line.
+func readJSONLines(body []byte) ([]Item, error) {
+	var items []Item
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+
+		if len(text) == 0 || bytes.HasPrefix(text, []byte("#")) {
+			continue
+		}
+
+		var item Item
+
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(&item); err != nil {
+			return nil, fmt.Errorf("line %d: failed to unmarshal item: %w", line, err)
+		}
+
+		item.Line = line
+
+		items = append(items, item)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	return items, nil
+}
+
+// parseHeaders parses the headers of the CSV headers column, `Name: value` separated by `|`.
+func parseHeaders(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	headers := make(map[string]string)
+
+	for _, header := range strings.Split(value, headerSeparator) {
+		name, headerValue, ok := strings.Cut(header, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q: expected Name: value", errInvalidHeader, strings.TrimSpace(header))
+		}
+
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(headerValue)
+	}
+
+	return headers, nil
+}
+
+// columnIndex returns the position of the column in the CSV header, or -1.
+func columnIndex(header []string, column string) int {
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,179 @@
+package input
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	type test struct {
+		input   string
+		format  string
+		want    []Item
+		wantErr error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully read a URL per line": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				input: "# docs\nhttps://example.com\n\n  https://example.com/docs#intro  \n",
+				want: []Item{
+					{URL: "https://example.com", Line: 2},
+					{URL: "https://example.com/docs#intro", Line: 4},
+				},
+			}
+		},
+		"Successfully read CSV": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				input: "# sites\nURL,depth,headers,output\n" +
+					"https://example.com,2,Authorization: Bearer x | Accept: text/html,example\n" +
+					"https://example.org\n",
+				want: []Item{
+					{
+						URL:     "https://example.com",
+						Output:  "example",
+						Headers: map[string]string{"Authorization": "Bearer x", "Accept": "text/html"},
+						Depth:   2,
+						Line:    3,
+					},
+					{URL: "https://example.org", Line: 4},
+				},
+			}
+		},
+		"Successfully read JSON lines": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				input: `{"url": "https://example.com", "headers": {"Cookie": "a=1; b=2"}, "depth": 1}` + "\n\n" +
+					`{"url": "https://example.org", "output": "org"}`,
+				want: []Item{
+					{URL: "https://example.com", Headers: map[string]string{"Cookie": "a=1; b=2"}, Depth: 1, Line: 1},
+					{URL: "https://example.org", Output: "org", Line: 3},
+				},
+			}
+		},
+		"Successfully read a URL per line with a comma": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				input:  "https://example.com/a,b\n",
+				format: FormatLines,
+				want:   []Item{{URL: "https://example.com/a,b", Line: 1}},
+			}
+		},
+		"Failed read CSV without url column": func(t *testing.T) test {
+			t.Helper()
+
+			return test{input: "depth,output\n1,a\n", format: FormatCSV, wantErr: errInvalidInput}
+		},
+		"Failed read CSV with invalid depth": func(t *testing.T) test {
+			t.Helper()
+
+			return test{input: "url,depth\nhttps://example.com,one\n", wantErr: errInvalidDepth}
+		},
+		"Failed read CSV with invalid headers": func(t *testing.T) test {
+			t.Helper()
+
+			return test{input: "url,headers\nhttps://example.com,Authorization\n", wantErr: errInvalidHeader}
+		},
+		"Failed read unknown format": func(t *testing.T) test {
+			t.Helper()
+
+			return test{input: "https://example.com", format: "xml", wantErr: errUnknownFormat}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			got, err := Read(strings.NewReader(tt.input), tt.format)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadJSONLinesUnknownField(t *testing.T) {
+	_, err := Read(strings.NewReader(`{"url": "https://example.com", "dept": 1}`), FormatAuto)
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestValidate(t *testing.T) {
+	type test struct {
+		items   []Item
+		wantErr error
+		want    []string
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully validate items": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				items: []Item{
+					{URL: "https://example.com", Output: "sites/example", Headers: map[string]string{"Authorization": "Bearer x"}, Depth: 1},
+					{URL: "http://localhost:8080/docs"},
+				},
+			}
+		},
+		"Failed validate every invalid item": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				items: []Item{
+					{URL: "example.com", Line: 1},
+					{URL: "https://example.com", Line: 2},
+					{URL: "ftp://example.com", Line: 3},
+					{URL: "https://example.com", Output: "../escape", Line: 4},
+					{URL: "https://example.com", Output: "_store", Line: 5},
+					{URL: "https://example.com", Headers: map[string]string{"Bad Name": "x"}, Line: 6},
+					{URL: "https://example.com", Headers: map[string]string{"X-Test": "a\nb"}, Line: 7},
+					{URL: "https://example.com", Depth: -1},
+				},
+				wantErr: errInvalidInput,
+				want:    []string{"line 1: ", "line 3: ", "line 4: ", "line 5: ", "line 6: ", "line 7: ", "item 8: "},
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			err := Validate(tt.items)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.NotContains(t, err.Error(), "line 2: ")
+
+			for _, want := range tt.want {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestFormatFromName(t *testing.T) {
+	assert.Equal(t, FormatCSV, FormatFromName("urls.CSV"))
+	assert.Equal(t, FormatJSONLines, FormatFromName("urls.ndjson"))
+	assert.Equal(t, FormatLines, FormatFromName("urls.txt"))
+	assert.Equal(t, FormatAuto, FormatFromName("-"))
+}
@@ -0,0 +1,357 @@
+package jobs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journalExt is the extension of the journal file of a job.
+const journalExt = ".jsonl"
+
+// badExt is the extension added to the journal of a job which can't be replayed, see Quarantine.
+const badExt = ".bad"
+
+// Journal record types.
+const (
+	recordJob    = "job"
+	recordResult = "result"
+	recordAsset  = "asset"
+	recordURL    = "url"
+)
+
+var (
+	// errInvalidJobID represents an error message when the job ID isn't an ID generated by the queue.
+	errInvalidJobID = errors.New("invalid job ID")
+	// errJobNotFound represents an error message when the journal has no job with the given ID.
+	errJobNotFound = errors.New("job not found")
+)
+
+// jobIDPattern matches the IDs generated by newID, so an ID never escapes the journal directory.
+var jobIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// Asset is an asset downloaded for a URL of a job and saved in the store.
+type Asset struct {
+	URL    string `json:"url"`
+	Digest string `json:"digest"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+}
+
+// State is the state of a job replayed from the journal.
+type State struct {
+	Job Job
+	// Settings are the settings given to Create, e.g. the output directory of the CLI.
+	Settings json.RawMessage
+	// Assets are the assets downloaded for every URL of the job, keyed by the asset URL.
+	Assets []map[string]Asset
+	// URLSettings are the settings given to Add for every URL of the job, nil for the URLs of Create.
+	URLSettings []json.RawMessage
+}
+
+// Pending returns the positions of the URLs of the job which aren't done or skipped yet, including the failed ones.
+func (s *State) Pending() []int {
+	var pending []int
+
+	for i, result := range s.Job.Results {
+		if result.Status != StatusDone && result.Status != StatusSkipped {
+			pending = append(pending, i)
+		}
+	}
+
+	return pending
+}
+
+// record is a line of the journal.
+type record struct {
+	Type     string          `json:"type"`
+	Time     time.Time       `json:"time"`
+	Job      *Job            `json:"job,omitempty"`
+	Settings json.RawMessage `json:"settings,omitempty"`
+	Index    int             `json:"index"`
+	Result   *Result         `json:"result,omitempty"`
+	Asset    *Asset          `json:"asset,omitempty"`
+	URL      string          `json:"url,omitempty"`
+}
+
+// Journal persists the jobs as JSON lines in a local directory, a file per job.
+// Every change is appended and synced to disk, so the job can be resumed after an interruption or a crash.
+// The settings of a job may hold secrets, e.g. the HTTP headers of the CLI, so only the owner can read the journal.
+type Journal struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+// OpenJournal opens the journal in the directory, creating the directory if needed.
+func OpenJournal(dir string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create journal dir: %w", err)
+	}
+
+	return &Journal{dir: dir}, nil
+}
+
+// Create records a new job with its settings, which may be nil.
+func (j *Journal) Create(job Job, settings interface{}) error {
+	var raw json.RawMessage
+
+	if settings != nil {
+		body, err := json.Marshal(settings)
+		if err != nil {
+			return fmt.Errorf("failed to marshal settings: %w", err)
+		}
+
+		raw = body
+	}
+
+	return j.append(job.ID, record{Type: recordJob, Job: &job, Settings: raw})
+}
+
+// Finish records the result of the URL at position `index` of the job.
+func (j *Journal) Finish(jobID string, index int, result Result) error {
+	return j.append(jobID, record{Type: recordResult, Index: index, Result: &result})
+}
+
+// RecordAsset records an asset downloaded for the URL at position `index` of the job.
+func (j *Journal) RecordAsset(jobID string, index int, asset Asset) error {
+	return j.append(jobID, record{Type: recordAsset, Index: index, Asset: &asset})
+}
+
+// Add records a URL added to the job at position `index`, see Job.Add, with its settings which may be nil.
+func (j *Journal) Add(jobID string, index int, url string, settings interface{}) error {
+	var raw json.RawMessage
+
+	if settings != nil {
+		body, err := json.Marshal(settings)
+		if err != nil {
+			return fmt.Errorf("failed to marshal settings: %w", err)
+		}
+
+		raw = body
+	}
+
+	return j.append(jobID, record{Type: recordURL, Index: index, URL: url, Settings: raw})
+}
+
+// Load replays the journal of the job.
+// A torn line, e.g. after a crash while writing it, is ignored with the records after it still replayed.
+func (j *Journal) Load(jobID string) (*State, error) {
+	filename, err := j.path(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", errJobNotFound, jobID)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	var state *State
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+
+	for scanner.Scan() {
+		var r record
+
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+
+		if r.Type == recordJob && r.Job != nil {
+			state = &State{
+				Job:         *r.Job,
+				Settings:    r.Settings,
+				Assets:      make([]map[string]Asset, len(r.Job.Results)),
+				URLSettings: make([]json.RawMessage, len(r.Job.Results)),
+			}
+
+			continue
+		}
+
+		// The URLs are added in order of their position, a record with another position is ignored.
+		if state != nil && r.Type == recordURL && r.Index == len(state.Job.Results) {
+			state.Job.Results = append(state.Job.Results, Result{URL: r.URL, Status: StatusQueued})
+			state.Job.replay(r.Time)
+			state.Assets = append(state.Assets, nil)
+			state.URLSettings = append(state.URLSettings, r.Settings)
+
+			continue
+		}
+
+		if state == nil || r.Index < 0 || r.Index >= len(state.Job.Results) {
+			continue
+		}
+
+		switch {
+		case r.Type == recordResult && r.Result != nil:
+			state.Job.Results[r.Index] = *r.Result
+			state.Job.replay(r.Time)
+		case r.Type == recordAsset && r.Asset != nil:
+			if state.Assets[r.Index] == nil {
+				state.Assets[r.Index] = make(map[string]Asset)
+			}
+
+			state.Assets[r.Index][r.Asset.URL] = *r.Asset
+		}
+	}
+
+	if state == nil {
+		return nil, fmt.Errorf("%w: %s: journal has no job record", errJobNotFound, jobID)
+	}
+
+	return state, nil
+}
+
+// Remove removes the journal of the job, e.g. when it's finished and won't be resumed.
+func (j *Journal) Remove(jobID string) error {
+	filename, err := j.path(jobID)
+	if err != nil {
+		return err
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if err := os.Remove(filename); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove journal: %w", err)
+	}
+
+	return nil
+}
+
+// Quarantine renames the journal of the job with the badExt extension, so it's kept for inspection
+// but isn't listed by IDs anymore.
+func (j *Journal) Quarantine(jobID string) error {
+	filename, err := j.path(jobID)
+	if err != nil {
+		return err
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if err := os.Rename(filename, filename+badExt); err != nil {
+		return fmt.Errorf("failed to quarantine journal: %w", err)
+	}
+
+	return nil
+}
+
+// IDs returns the IDs of all jobs of the journal.
+func (j *Journal) IDs() ([]string, error) {
+	entries, err := os.ReadDir(j.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list journal: %w", err)
+	}
+
+	var ids []string
+
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), journalExt)
+
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), journalExt) && jobIDPattern.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// append writes the record as a line of the journal of the job and syncs it to disk.
+// A torn last line is ended first, so the record is never appended to it.
+func (j *Journal) append(jobID string, r record) error {
+	filename, err := j.path(jobID)
+	if err != nil {
+		return err
+	}
+
+	r.Time = time.Now().UTC()
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal record: %w", err)
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	torn, err := endsTorn(file)
+	if err != nil {
+		_ = file.Close()
+
+		return err
+	}
+
+	if torn {
+		line = append([]byte{'\n'}, line...)
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close journal: %w", err)
+	}
+
+	return nil
+}
+
+// endsTorn reports whether the journal file doesn't end with a newline, i.e. its last line is torn.
+func endsTorn(file *os.File) (bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat journal: %w", err)
+	}
+
+	if info.Size() == 0 {
+		return false, nil
+	}
+
+	last := make([]byte, 1)
+
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return false, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	return last[0] != '\n', nil
+}
+
+// path returns the journal file of the job.
+func (j *Journal) path(jobID string) (string, error) {
+	if !jobIDPattern.MatchString(jobID) {
+		return "", fmt.Errorf("%w: %q", errInvalidJobID, jobID)
+	}
+
+	return filepath.Join(j.dir, jobID+journalExt), nil
+}
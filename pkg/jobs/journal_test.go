@@ -0,0 +1,237 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+
+	journal, err := OpenJournal(dir)
+	require.NoError(t, err)
+
+	job, err := NewJob([]string{"https://a.com", "https://b.com", "https://c.com"}, Options{Metadata: true})
+	require.NoError(t, err)
+
+	require.NoError(t, journal.Create(job, map[string]string{"output_dir": "./archives"}))
+	require.NoError(t, journal.Finish(job.ID, 0, Result{URL: "https://a.com", Status: StatusDone, Snapshot: "20261019T100000Z"}))
+	require.NoError(t, journal.RecordAsset(job.ID, 1, Asset{URL: "/style.css", Digest: "abc", Path: "_store/sha256/ab/abc.css", Size: 3}))
+	require.NoError(t, journal.Finish(job.ID, 2, Result{URL: "https://c.com", Status: StatusFailed, Error: "boom"}))
+	require.NoError(t, journal.Add(job.ID, 3, "https://a.com/docs", map[string]int{"depth": 1}))
+	// A URL with another position than the next one is ignored.
+	require.NoError(t, journal.Add(job.ID, 9, "https://a.com/blog", nil))
+
+	// A crash while writing leaves a torn last line.
+	file, err := os.OpenFile(filepath.Join(dir, job.ID+journalExt), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"type":"result","index":1,"res`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	state, err := journal.Load(job.ID)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"output_dir": "./archives"}`, string(state.Settings))
+	assert.True(t, state.Job.Options.Metadata)
+	assert.Equal(t, StatusDone, state.Job.Results[0].Status)
+	assert.Equal(t, StatusQueued, state.Job.Results[1].Status)
+	assert.Equal(t, "boom", state.Job.Results[2].Error)
+	assert.Equal(t, "abc", state.Assets[1]["/style.css"].Digest)
+	require.Len(t, state.Job.Results, 4)
+	assert.Equal(t, Result{URL: "https://a.com/docs", Status: StatusQueued}, state.Job.Results[3])
+	assert.JSONEq(t, `{"depth": 1}`, string(state.URLSettings[3]))
+	assert.Nil(t, state.URLSettings[0])
+	assert.Equal(t, []int{1, 2, 3}, state.Pending())
+
+	// The records appended after the torn line are replayed.
+	require.NoError(t, journal.Finish(job.ID, 1, Result{URL: "https://b.com", Status: StatusDone}))
+	require.NoError(t, journal.Finish(job.ID, 3, Result{URL: "https://a.com/docs", Status: StatusDone}))
+
+	state, err = journal.Load(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, state.Pending())
+
+	ids, err := journal.IDs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{job.ID}, ids)
+
+	type test struct {
+		id      string
+		wantErr error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Failed load job with an invalid ID": func(t *testing.T) test {
+			t.Helper()
+
+			return test{id: "../../etc/passwd", wantErr: errInvalidJobID}
+		},
+		"Failed load missing job": func(t *testing.T) test {
+			t.Helper()
+
+			return test{id: "0123456789abcdef", wantErr: errJobNotFound}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			_, err := journal.Load(tt.id)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestQueueResume(t *testing.T) {
+	journal, err := OpenJournal(t.TempDir())
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+
+	runner := RunnerFunc(func(ctx context.Context, task Task) (Result, error) {
+		if task.URL == "https://a.com" {
+			return Result{Snapshot: "20261019T100000Z"}, task.RecordAsset(Asset{URL: "/app.js", Digest: "def"})
+		}
+
+		select {
+		case <-release:
+			return Result{}, nil
+		case <-ctx.Done():
+			return Result{}, ctx.Err()
+		}
+	})
+
+	q, err := New(runner, 1, 10, WithJournal(journal))
+	require.NoError(t, err)
+
+	job, err := q.Submit([]string{"https://a.com", "https://b.com", "https://c.com"}, Options{})
+	require.NoError(t, err)
+
+	waitStatus(t, q, job.ID, StatusRunning)
+
+	require.Eventually(t, func() bool {
+		current, _ := q.Get(job.ID)
+
+		return current.Results[1].Status == StatusRunning
+	}, time.Second, time.Millisecond)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The running URL is interrupted, it isn't recorded as finished.
+	assert.ErrorIs(t, q.Shutdown(canceled), context.Canceled)
+
+	state, err := journal.Load(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, state.Pending())
+	assert.Equal(t, "def", state.Assets[0]["/app.js"].Digest)
+
+	// The next queue resumes the URLs which aren't finished.
+	var mutex sync.Mutex
+
+	var resumed []string
+
+	close(release)
+
+	runner = RunnerFunc(func(ctx context.Context, task Task) (Result, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		resumed = append(resumed, task.URL)
+
+		return Result{}, nil
+	})
+
+	q, err = New(runner, 1, 10, WithJournal(journal))
+	require.NoError(t, err)
+
+	resumedJob := waitStatus(t, q, job.ID, StatusDone)
+	assert.Equal(t, "20261019T100000Z", resumedJob.Results[0].Snapshot)
+
+	require.NoError(t, q.Shutdown(context.Background()))
+
+	assert.ElementsMatch(t, []string{"https://b.com", "https://c.com"}, resumed)
+}
+
+func TestQueueRetention(t *testing.T) {
+	journal, err := OpenJournal(t.TempDir())
+	require.NoError(t, err)
+
+	runner := RunnerFunc(func(ctx context.Context, task Task) (Result, error) {
+		return Result{}, nil
+	})
+
+	q, err := New(runner, 1, 10, WithJournal(journal), WithRetention(time.Hour))
+	require.NoError(t, err)
+
+	defer func() { _ = q.Shutdown(context.Background()) }()
+
+	job, err := q.Submit([]string{"https://a.com"}, Options{})
+	require.NoError(t, err)
+
+	waitStatus(t, q, job.ID, StatusDone)
+
+	// The job finished longer ago than the retention.
+	q.mutex.Lock()
+	finishedAt := q.jobs[job.ID].FinishedAt.Add(-2 * time.Hour)
+	q.jobs[job.ID].FinishedAt = &finishedAt
+	q.mutex.Unlock()
+
+	// The finished job is removed when the next one is submitted.
+	next, err := q.Submit([]string{"https://b.com"}, Options{})
+	require.NoError(t, err)
+
+	_, ok := q.Get(job.ID)
+	assert.False(t, ok)
+
+	_, err = journal.Load(job.ID)
+	assert.ErrorIs(t, err, errJobNotFound)
+
+	_, ok = q.Get(next.ID)
+	assert.True(t, ok)
+}
+
+func TestQueueCorruptJournal(t *testing.T) {
+	dir := t.TempDir()
+
+	journal, err := OpenJournal(dir)
+	require.NoError(t, err)
+
+	job, err := NewJob([]string{"https://a.com"}, Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, journal.Create(job, nil))
+
+	// A crash while creating a job leaves a journal without job record.
+	corrupt := filepath.Join(dir, "0123456789abcdef"+journalExt)
+	require.NoError(t, os.WriteFile(corrupt, []byte(`{"type":"jo`), 0o600))
+
+	runner := RunnerFunc(func(ctx context.Context, task Task) (Result, error) {
+		return Result{}, nil
+	})
+
+	q, err := New(runner, 1, 10, WithJournal(journal))
+	require.NoError(t, err)
+
+	defer func() { _ = q.Shutdown(context.Background()) }()
+
+	waitStatus(t, q, job.ID, StatusDone)
+
+	_, ok := q.Get("0123456789abcdef")
+	assert.False(t, ok)
+
+	assert.FileExists(t, corrupt+badExt)
+
+	ids, err := journal.IDs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{job.ID}, ids)
+}
@@ -0,0 +1,625 @@
+// Package jobs runs the fetch jobs submitted to the daemon with a bounded number of workers,
+// and persists them in a journal so an interrupted job can be resumed.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned when the queue can't take the URLs of a new job.
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrJobTooLarge is returned when a job has more URLs than the queue can ever take, so it can't be retried.
+	ErrJobTooLarge = errors.New("job has more URLs than the queue size")
+	// ErrQueueClosed is returned when a job is submitted after the queue is shut down.
+	ErrQueueClosed = errors.New("job queue is shut down")
+	// errNoURL represents an error message when a job is submitted without any URL.
+	errNoURL = errors.New("job has no URL")
+)
+
+// Job status.
+const (
+	StatusQueued   = "queued"
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+	// StatusSkipped is the status of a URL skipped by the filter rules, it counts as done.
+	StatusSkipped = "skipped"
+)
+
+// Error types of a failed URL.
+const (
+	// ErrorTimeout is the error type of a URL failed by a timeout.
+	ErrorTimeout = "timeout"
+	// ErrorTooLarge is the error type of a URL whose response is larger than the size limit, set by the runner.
+	ErrorTooLarge = "too_large"
+	// ErrorContentType is the error type of a URL whose response has an unexpected content type, set by the runner.
+	ErrorContentType = "content_type"
+	// ErrorBlocked is the error type of a URL whose host resolves to a denied address, set by the runner.
+	ErrorBlocked = "blocked"
+	// ErrorRedirect is the error type of a URL whose redirect is refused by the redirect policy, set by the runner.
+	ErrorRedirect = "redirect"
+	// ErrorTLS is the error type of a URL whose certificate isn't trusted or doesn't match the pins, set by the runner.
+	ErrorTLS = "tls"
+)
+
+// Options are the options of a job, applied to every URL of the job.
+type Options struct {
+	// Metadata fetches the assets, saves the metadata and zips the page.
+	Metadata bool `json:"metadata"`
+}
+
+// Result is the result of fetching one URL of a job.
+type Result struct {
+	URL    string `json:"url"`
+	Status string `json:"status"`
+	// Snapshot is the ID of the snapshot saved for the URL.
+	Snapshot string `json:"snapshot,omitempty"`
+	// Page, Archive and Metadata are the storage names of the saved files, empty if they aren't saved.
+	Page     string `json:"page,omitempty"`
+	Archive  string `json:"archive,omitempty"`
+	Metadata string `json:"metadata,omitempty"`
+	// Error is the error of a failed URL, or the reason of a skipped one.
+	Error string `json:"error,omitempty"`
+	// ErrorType classifies the error of a failed URL, e.g. ErrorTimeout, empty for the other errors.
+	ErrorType string `json:"error_type,omitempty"`
+}
+
+// Job is a set of URLs fetched with the same options.
+type Job struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Options    Options    `json:"options"`
+	Results    []Result   `json:"results"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Summary counts the URLs of a job by their status.
+type Summary struct {
+	Done    int `json:"done"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+	// Pending are the URLs which aren't finished, queued, running or canceled.
+	Pending int `json:"pending"`
+}
+
+// Task is a URL of a job given to the Runner.
+type Task struct {
+	JobID   string
+	Index   int
+	URL     string
+	Options Options
+	// Headers are the HTTP headers sent when fetching the page, nil for the jobs of the queue.
+	Headers map[string]string
+	// Assets are the assets already downloaded for the URL by a previous run of the job, keyed by the asset URL.
+	Assets map[string]Asset
+	// RecordAsset persists a downloaded asset so a resumed job doesn't download it again, nil if the job isn't persisted.
+	RecordAsset func(asset Asset) error
+}
+
+// Runner fetches the URL of a task and returns its result.
+type Runner interface {
+	Run(ctx context.Context, task Task) (Result, error)
+}
+
+// RunnerFunc is a function implementing Runner.
+type RunnerFunc func(ctx context.Context, task Task) (Result, error)
+
+// Run calls the function.
+func (f RunnerFunc) Run(ctx context.Context, task Task) (Result, error) {
+	return f(ctx, task)
+}
+
+// Option is a function to set the options of the Queue.
+type Option func(*Queue)
+
+// WithJournal persists the jobs in the journal. The unfinished jobs of the journal,
+// e.g. interrupted by a shutdown or a crash, are resumed by New.
+func WithJournal(journal *Journal) Option {
+	return func(q *Queue) {
+		q.journal = journal
+	}
+}
+
+// WithRetention removes the jobs done or failed for longer than the retention, with their journal.
+// Zero keeps every job.
+func WithRetention(retention time.Duration) Option {
+	return func(q *Queue) {
+		q.retention = retention
+	}
+}
+
+// task is a URL of a job waiting for a worker.
+type task struct {
+	jobID string
+	index int
+}
+
+// Queue keeps the submitted jobs and runs their URLs with a bounded number of workers.
+type Queue struct {
+	runner    Runner
+	tasks     chan task
+	journal   *Journal
+	retention time.Duration
+
+	mutex    sync.Mutex
+	jobs     map[string]*Job
+	assets   map[string][]map[string]Asset
+	closed   bool
+	stopping bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a Queue running the URLs with `workers` workers, at most `capacity` URLs can wait for a worker.
+func New(runner Runner, workers, capacity int, opts ...Option) (*Queue, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	q := &Queue{
+		runner: runner,
+		jobs:   make(map[string]*Job),
+		assets: make(map[string][]map[string]Asset),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	pending, err := q.load()
+	if err != nil {
+		cancel()
+
+		return nil, err
+	}
+
+	// The resumed URLs always fit in the queue.
+	if len(pending) > capacity {
+		capacity = len(pending)
+	}
+
+	q.tasks = make(chan task, capacity)
+
+	for _, t := range pending {
+		q.tasks <- t
+	}
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+
+		go q.work()
+	}
+
+	return q, nil
+}
+
+// load reads the jobs of the journal and returns the URLs which aren't finished.
+// The failed URLs are final, they are only retried by resuming the job explicitly.
+func (q *Queue) load() ([]task, error) {
+	if q.journal == nil {
+		return nil, nil
+	}
+
+	ids, err := q.journal.IDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []task
+
+	for _, id := range ids {
+		// A journal which can't be replayed, e.g. without job record after a crash in Create,
+		// is set aside so the other jobs are still resumed.
+		state, err := q.journal.Load(id)
+		if err != nil {
+			log.Printf("skipping job %s: %v", id, err)
+
+			if err := q.journal.Quarantine(id); err != nil {
+				log.Println(err)
+			}
+
+			continue
+		}
+
+		job := state.Job
+
+		for i := range job.Results {
+			if job.Results[i].Status == StatusQueued || job.Results[i].Status == StatusRunning {
+				job.Results[i].Status = StatusQueued
+
+				pending = append(pending, task{jobID: id, index: i})
+			}
+		}
+
+		job.Status = job.status()
+
+		q.jobs[id] = &job
+		q.assets[id] = state.Assets
+	}
+
+	q.expire(time.Now())
+
+	return pending, nil
+}
+
+// NewJob returns a queued job fetching the URLs with the options.
+func NewJob(urls []string, options Options) (Job, error) {
+	if len(urls) == 0 {
+		return Job{}, errNoURL
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	job := Job{
+		ID:        id,
+		Status:    StatusQueued,
+		Options:   options,
+		Results:   make([]Result, len(urls)),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	for i, url := range urls {
+		job.Results[i] = Result{URL: url, Status: StatusQueued}
+	}
+
+	return job, nil
+}
+
+// Submit adds a job fetching the URLs with the options, the job is rejected if the queue is full
+// or if it has more URLs than the queue size.
+func (q *Queue) Submit(urls []string, options Options) (Job, error) {
+	job, err := NewJob(urls, options)
+	if err != nil {
+		return Job{}, err
+	}
+
+	if len(urls) > cap(q.tasks) {
+		return Job{}, fmt.Errorf("%w: %d URLs, the queue takes %d", ErrJobTooLarge, len(urls), cap(q.tasks))
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return Job{}, ErrQueueClosed
+	}
+
+	if len(q.tasks)+len(urls) > cap(q.tasks) {
+		return Job{}, fmt.Errorf("%w: %d URLs waiting", ErrQueueFull, len(q.tasks))
+	}
+
+	q.expire(time.Now())
+
+	if q.journal != nil {
+		if err := q.journal.Create(job, nil); err != nil {
+			return Job{}, err
+		}
+	}
+
+	q.jobs[job.ID] = &job
+
+	// The capacity is checked above and only the workers receive, so sending never blocks.
+	for i := range urls {
+		q.tasks <- task{jobID: job.ID, index: i}
+	}
+
+	return job.copy(), nil
+}
+
+// Get returns the job with the given ID.
+func (q *Queue) Get(id string) (Job, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+
+	return job.copy(), true
+}
+
+// List returns all jobs from the oldest to the latest.
+func (q *Queue) List() []Job {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, job.copy())
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].ID < jobs[j].ID
+		}
+
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+
+	return jobs
+}
+
+// Shutdown stops accepting jobs, cancels the URLs still waiting for a worker
+// and waits for the running ones to finish. The running URLs are canceled if the context is done first.
+// With a journal, the canceled URLs are resumed by the next queue.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mutex.Lock()
+
+	if !q.closed {
+		q.closed = true
+		q.stopping = true
+
+		close(q.tasks)
+	}
+
+	q.mutex.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+
+		<-done
+
+		return fmt.Errorf("failed to wait for running jobs: %w", ctx.Err())
+	}
+}
+
+// work runs the tasks until the queue is shut down.
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for t := range q.tasks {
+		runTask, ok := q.start(t)
+		if !ok {
+			continue
+		}
+
+		result, err := q.runner.Run(q.ctx, runTask)
+
+		q.finish(t, result, err)
+	}
+}
+
+// start marks the task as running and returns the task for the runner,
+// or marks it as canceled if the queue is shutting down and returns false.
+func (q *Queue) start(t task) (Task, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job := q.jobs[t.jobID]
+
+	if q.stopping {
+		job.Results[t.index].Status = StatusCanceled
+		job.update()
+
+		return Task{}, false
+	}
+
+	job.Results[t.index].Status = StatusRunning
+	job.update()
+
+	runTask := Task{
+		JobID:   t.jobID,
+		Index:   t.index,
+		URL:     job.Results[t.index].URL,
+		Options: job.Options,
+	}
+
+	if assets := q.assets[t.jobID]; t.index < len(assets) {
+		runTask.Assets = assets[t.index]
+	}
+
+	if q.journal != nil {
+		runTask.RecordAsset = func(asset Asset) error {
+			return q.journal.RecordAsset(t.jobID, t.index, asset)
+		}
+	}
+
+	return runTask, true
+}
+
+// finish records the result of the task.
+func (q *Queue) finish(t task, result Result, err error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	result = q.jobs[t.jobID].Finish(t.index, result, err)
+
+	// A URL interrupted by the shutdown is resumed by the next queue, so its failure isn't recorded.
+	if q.journal != nil && q.ctx.Err() == nil {
+		if err := q.journal.Finish(t.jobID, t.index, result); err != nil {
+			log.Println(err)
+		}
+	}
+
+	q.expire(time.Now())
+}
+
+// expire removes the jobs done or failed for longer than the retention, with their journal.
+// The canceled jobs are resumed by the next queue, so they're never removed.
+func (q *Queue) expire(now time.Time) {
+	if q.retention <= 0 {
+		return
+	}
+
+	for id, job := range q.jobs {
+		finished := job.Status == StatusDone || job.Status == StatusFailed
+		if !finished || job.FinishedAt == nil || now.Sub(*job.FinishedAt) <= q.retention {
+			continue
+		}
+
+		if q.journal != nil {
+			if err := q.journal.Remove(id); err != nil {
+				log.Println(err)
+
+				continue
+			}
+		}
+
+		delete(q.jobs, id)
+		delete(q.assets, id)
+	}
+}
+
+// Finish records the result of the URL at position `index` of the job, e.g. when the job isn't run by a Queue.
+// The result status is set from the error, and its error type unless the runner set it.
+func (j *Job) Finish(index int, result Result, err error) Result {
+	result.URL = j.Results[index].URL
+	result.Status = StatusDone
+
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+		if result.ErrorType == "" {
+			result.ErrorType = errorType(err)
+		}
+	}
+
+	j.Results[index] = result
+	j.update()
+
+	return result
+}
+
+// errorType returns the error type of the error, e.g. ErrorTimeout for an error with a true Timeout method like net.Error.
+func errorType(err error) string {
+	var timeoutErr interface{ Timeout() bool }
+	if errors.As(err, &timeoutErr) && timeoutErr.Timeout() {
+		return ErrorTimeout
+	}
+
+	return ""
+}
+
+// Skip records the URL at position `index` of the job as skipped with the reason.
+func (j *Job) Skip(index int, reason string) Result {
+	result := Result{URL: j.Results[index].URL, Status: StatusSkipped, Error: reason}
+
+	j.Results[index] = result
+	j.update()
+
+	return result
+}
+
+// Add appends a queued URL to the job, e.g. a link found while crawling, and returns its position.
+func (j *Job) Add(url string) int {
+	j.Results = append(j.Results, Result{URL: url, Status: StatusQueued})
+	j.update()
+
+	return len(j.Results) - 1
+}
+
+// Summary counts the URLs of the job by their status.
+func (j *Job) Summary() Summary {
+	var summary Summary
+
+	for _, result := range j.Results {
+		switch result.Status {
+		case StatusDone:
+			summary.Done++
+		case StatusFailed:
+			summary.Failed++
+		case StatusSkipped:
+			summary.Skipped++
+		default:
+			summary.Pending++
+		}
+	}
+
+	return summary
+}
+
+// update derives the job status from the status of its URLs and sets the start and finish times.
+func (j *Job) update() {
+	j.replay(time.Now().UTC())
+}
+
+// replay updates the job as if its URLs changed at the given time.
+func (j *Job) replay(at time.Time) {
+	j.Status = j.status()
+
+	if j.StartedAt == nil && j.Status != StatusQueued {
+		j.StartedAt = &at
+	}
+
+	if j.FinishedAt == nil && (j.Status == StatusDone || j.Status == StatusFailed || j.Status == StatusCanceled) {
+		j.FinishedAt = &at
+	}
+}
+
+// status derives the job status from the status of its URLs.
+func (j *Job) status() string {
+	counts := make(map[string]int)
+	for _, result := range j.Results {
+		counts[result.Status]++
+	}
+
+	switch {
+	case counts[StatusQueued] == len(j.Results):
+		return StatusQueued
+	case counts[StatusQueued]+counts[StatusRunning] > 0:
+		return StatusRunning
+	case counts[StatusCanceled] > 0:
+		return StatusCanceled
+	case counts[StatusFailed] > 0:
+		return StatusFailed
+	default:
+		return StatusDone
+	}
+}
+
+// copy returns a copy of the job which can be read without the lock.
+func (j *Job) copy() Job {
+	job := *j
+	job.Results = append([]Result(nil), j.Results...)
+
+	return job
+}
+
+// newID returns a random job ID.
+func newID() (string, error) {
+	id := make([]byte, 8)
+
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	return hex.EncodeToString(id), nil
+}
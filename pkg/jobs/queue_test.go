@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitStatus waits until the job has the given status.
+func waitStatus(t *testing.T, q *Queue, id, status string) Job {
+	t.Helper()
+
+	var job Job
+
+	require.Eventually(t, func() bool {
+		job, _ = q.Get(id)
+
+		return job.Status == status
+	}, time.Second, time.Millisecond)
+
+	return job
+}
+
+func TestQueue(t *testing.T) {
+	runner := RunnerFunc(func(ctx context.Context, task Task) (Result, error) {
+		if task.URL == "https://fail.com" {
+			return Result{}, errors.New("boom")
+		}
+
+		return Result{Snapshot: "20261019T100000Z", Page: task.URL + "/_page.html"}, nil
+	})
+
+	q, err := New(runner, 2, 10)
+	require.NoError(t, err)
+
+	done, err := q.Submit([]string{"https://a.com", "https://b.com"}, Options{Metadata: true})
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, done.Status)
+
+	failed, err := q.Submit([]string{"https://a.com", "https://fail.com"}, Options{})
+	require.NoError(t, err)
+
+	job := waitStatus(t, q, done.ID, StatusDone)
+	assert.True(t, job.Options.Metadata)
+	assert.Equal(t, Result{URL: "https://b.com", Status: StatusDone, Snapshot: "20261019T100000Z", Page: "https://b.com/_page.html"}, job.Results[1])
+	assert.NotNil(t, job.StartedAt)
+	assert.NotNil(t, job.FinishedAt)
+
+	job = waitStatus(t, q, failed.ID, StatusFailed)
+	assert.Equal(t, StatusDone, job.Results[0].Status)
+	assert.Equal(t, "boom", job.Results[1].Error)
+
+	jobs := q.List()
+	require.Len(t, jobs, 2)
+
+	_, ok := q.Get("missing")
+	assert.False(t, ok)
+
+	_, err = q.Submit(nil, Options{})
+	assert.ErrorIs(t, err, errNoURL)
+
+	require.NoError(t, q.Shutdown(context.Background()))
+
+	_, err = q.Submit([]string{"https://a.com"}, Options{})
+	assert.ErrorIs(t, err, ErrQueueClosed)
+}
+
+func TestQueueFull(t *testing.T) {
+	release := make(chan struct{})
+
+	runner := RunnerFunc(func(ctx context.Context, task Task) (Result, error) {
+		<-release
+
+		return Result{}, nil
+	})
+
+	q, err := New(runner, 1, 2)
+	require.NoError(t, err)
+
+	running, err := q.Submit([]string{"https://a.com"}, Options{})
+	require.NoError(t, err)
+
+	// The worker takes the first URL, the two others wait in the queue.
+	waitStatus(t, q, running.ID, StatusRunning)
+
+	_, err = q.Submit([]string{"https://b.com", "https://c.com"}, Options{})
+	require.NoError(t, err)
+
+	_, err = q.Submit([]string{"https://d.com"}, Options{})
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	// A job which can never fit in the queue isn't full but too large.
+	_, err = q.Submit([]string{"https://e.com", "https://f.com", "https://g.com"}, Options{})
+	assert.ErrorIs(t, err, ErrJobTooLarge)
+
+	close(release)
+
+	require.NoError(t, q.Shutdown(context.Background()))
+}
+
+func TestQueueShutdown(t *testing.T) {
+	var started int32
+
+	runner := RunnerFunc(func(ctx context.Context, task Task) (Result, error) {
+		atomic.AddInt32(&started, 1)
+
+		<-ctx.Done()
+
+		return Result{}, ctx.Err()
+	})
+
+	q, err := New(runner, 1, 10)
+	require.NoError(t, err)
+
+	job, err := q.Submit([]string{"https://a.com", "https://b.com"}, Options{})
+	require.NoError(t, err)
+
+	waitStatus(t, q, job.ID, StatusRunning)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// The running URL doesn't finish before the deadline, so it's canceled.
+	err = q.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	job, _ = q.Get(job.ID)
+	assert.Equal(t, StatusCanceled, job.Status)
+	assert.Equal(t, StatusFailed, job.Results[0].Status)
+	assert.Equal(t, StatusCanceled, job.Results[1].Status)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&started))
+}
+
+func TestJobSummary(t *testing.T) {
+	job, err := NewJob([]string{"https://a.com", "https://b.com", "https://c.com", "https://d.com"}, Options{})
+	require.NoError(t, err)
+
+	job.Finish(0, Result{}, nil)
+	job.Finish(1, Result{}, errors.New("boom"))
+	job.Skip(2, "skipped by filter")
+
+	assert.Equal(t, Summary{Done: 1, Failed: 1, Skipped: 1, Pending: 1}, job.Summary())
+}
+
+func TestJobFinishTimeout(t *testing.T) {
+	job, err := NewJob([]string{"https://a.com", "https://b.com"}, Options{})
+	require.NoError(t, err)
+
+	// Like net.Error, context.DeadlineExceeded has a Timeout method.
+	result := job.Finish(0, Result{}, fmt.Errorf("failed to fetch page: %w", context.DeadlineExceeded))
+	assert.Equal(t, StatusFailed, result.Status)
+	assert.Equal(t, ErrorTimeout, result.ErrorType)
+	assert.Equal(t, "failed to fetch page: context deadline exceeded", result.Error)
+
+	result = job.Finish(1, Result{}, errors.New("boom"))
+	assert.Equal(t, StatusFailed, result.Status)
+	assert.Empty(t, result.ErrorType)
+
+	// The error type set by the runner is kept.
+	result = job.Finish(1, Result{ErrorType: ErrorTooLarge}, errors.New("boom"))
+	assert.Equal(t, ErrorTooLarge, result.ErrorType)
+}
@@ -0,0 +1,334 @@
+// Package output writes the results of the fetched URLs in machine-readable formats,
+// so scripts don't have to scrape the text printed on the console.
+package output
+
+import (
+	_ "embed" // Embeds the JSON Schema of the records.
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/fetcher"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats.
+const (
+	// FormatText prints the metadata on the console for humans, it has no Writer.
+	FormatText = "text"
+	// FormatJSON writes a JSON array of the records.
+	FormatJSON = "json"
+	// FormatNDJSON writes a JSON record per line.
+	FormatNDJSON = "ndjson"
+	// FormatCSV writes a CSV row per record with the CSVHeader columns.
+	FormatCSV = "csv"
+	// FormatYAML writes a YAML document per record.
+	FormatYAML = "yaml"
+)
+
+// Version is the version of the record schema, it's increased when a field is renamed or removed.
+const Version = 1
+
+// Record status.
+const (
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// errUnknownFormat represents an error message when the output format isn't supported.
+var errUnknownFormat = errors.New("unknown output format")
+
+// Schema is the JSON Schema of the records, see schema.json.
+//
+//go:embed schema.json
+var Schema []byte
+
+// CSVHeader are the columns of the CSV format, the lists are joined with a space.
+var CSVHeader = []string{
+	"version", "url", "status", "error", "snapshot", "page", "archive", "fetched_at",
+	"site", "title", "num_links", "images", "assets", "last_fetch", "skipped", "failed", "error_type", "final_url",
+}
+
+// Record is the result of fetching a URL.
+type Record struct {
+	// Version is the version of the record schema.
+	Version int    `json:"version"`
+	URL     string `json:"url"`
+	Status  string `json:"status"`
+	// Error is the error of a failed URL, or the reason of a skipped one.
+	Error string `json:"error,omitempty"`
+	// ErrorType classifies the error of a failed URL, e.g. jobs.ErrorTimeout.
+	ErrorType string `json:"error_type,omitempty"`
+	// Snapshot is the ID of the snapshot saved for the URL.
+	Snapshot string `json:"snapshot,omitempty"`
+	// Page and Archive are the storage names of the saved files, empty if they aren't saved.
+	Page      string     `json:"page,omitempty"`
+	Archive   string     `json:"archive,omitempty"`
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+	// Metadata is the metadata of the page fetched with --metadata.
+	Metadata *fetcher.Metadata `json:"metadata,omitempty"`
+}
+
+// Writer writes the records, it's safe for concurrent use.
+type Writer interface {
+	// Write writes the record.
+	Write(record Record) error
+	// Close writes the end of the output, e.g. the end of the JSON array.
+	Close() error
+}
+
+// NewWriter returns the Writer of the machine-readable format writing to w.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{w: w}, nil
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatYAML:
+		return &yamlWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s, expected %s", errUnknownFormat, format, strings.Join(Formats[1:], ", "))
+	}
+}
+
+// Formats are the supported output formats, the text format first.
+var Formats = []string{FormatText, FormatJSON, FormatNDJSON, FormatCSV, FormatYAML}
+
+// jsonWriter writes a JSON array, every record is written as soon as it's fetched.
+type jsonWriter struct {
+	w      io.Writer
+	mutex  sync.Mutex
+	count  int
+	closed bool
+}
+
+func (j *jsonWriter) Write(record Record) error {
+	body, err := json.MarshalIndent(record, "  ", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	separator := ",\n  "
+	if j.count == 0 {
+		separator = "[\n  "
+	}
+
+	j.count++
+
+	if _, err := io.WriteString(j.w, separator+string(body)); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	return nil
+}
+
+func (j *jsonWriter) Close() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.closed {
+		return nil
+	}
+
+	j.closed = true
+
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+
+	if _, err := io.WriteString(j.w, end); err != nil {
+		return fmt.Errorf("failed to write records: %w", err)
+	}
+
+	return nil
+}
+
+// ndjsonWriter writes a JSON record per line.
+type ndjsonWriter struct {
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+func (n *ndjsonWriter) Write(record Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if _, err := n.w.Write(append(body, '\n')); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	return nil
+}
+
+func (n *ndjsonWriter) Close() error { return nil }
+
+// csvWriter writes a CSV row per record, the header row is written before the first record.
+type csvWriter struct {
+	w      *csv.Writer
+	mutex  sync.Mutex
+	header bool
+}
+
+func (c *csvWriter) Write(record Record) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	if err := c.w.Write(csvRow(record)); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	// Every row is flushed, so the records can be read while the URLs are fetched.
+	c.w.Flush()
+
+	if err := c.w.Error(); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	return nil
+}
+
+func (c *csvWriter) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	c.w.Flush()
+
+	if err := c.w.Error(); err != nil {
+		return fmt.Errorf("failed to write records: %w", err)
+	}
+
+	return nil
+}
+
+// writeHeader writes the header row once.
+func (c *csvWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+
+	c.header = true
+
+	if err := c.w.Write(CSVHeader); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	return nil
+}
+
+// csvRow returns the columns of the record in the order of CSVHeader.
+func csvRow(record Record) []string {
+	row := []string{
+		strconv.Itoa(record.Version), record.URL, record.Status, record.Error,
+		record.Snapshot, record.Page, record.Archive, formatTime(record.FetchedAt),
+	}
+
+	metadata := record.Metadata
+	if metadata == nil {
+		return append(row, "", "", "", "", "", "", "", "", record.ErrorType, "")
+	}
+
+	skipped := make([]string, len(metadata.Skipped))
+	for i, item := range metadata.Skipped {
+		skipped[i] = item.URL
+	}
+
+	failed := make([]string, len(metadata.Failed))
+	for i, item := range metadata.Failed {
+		failed[i] = item.URL
+	}
+
+	return append(row,
+		metadata.Site,
+		metadata.Title,
+		strconv.FormatInt(metadata.NumLinks, 10),
+		strconv.FormatInt(metadata.Images, 10),
+		strings.Join(metadata.Assets, " "),
+		formatTime(&metadata.LastFetch),
+		strings.Join(skipped, " "),
+		strings.Join(failed, " "),
+		record.ErrorType,
+		metadata.FinalURL,
+	)
+}
+
+// yamlWriter writes a YAML document per record.
+type yamlWriter struct {
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+func (y *yamlWriter) Write(record Record) error {
+	// The record is converted from its JSON, so the YAML fields are named and ordered like the JSON ones.
+	body, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	var node yaml.Node
+
+	if err := yaml.Unmarshal(body, &node); err != nil {
+		return fmt.Errorf("failed to convert record: %w", err)
+	}
+
+	blockStyle(&node)
+
+	document, err := yaml.Marshal(&node)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+
+	if _, err := io.WriteString(y.w, "---\n"+string(document)); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	return nil
+}
+
+func (y *yamlWriter) Close() error { return nil }
+
+// blockStyle writes the node in the block style instead of the flow style of JSON.
+func blockStyle(node *yaml.Node) {
+	node.Style &^= yaml.FlowStyle | yaml.DoubleQuotedStyle
+
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// formatTime formats the time as RFC3339, empty for a nil or zero time.
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,216 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/fetcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	fetchedAt := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+
+	records := []Record{
+		{
+			Version:   Version,
+			URL:       "https://example.com",
+			Status:    StatusDone,
+			Snapshot:  "20261001T100000Z",
+			Page:      "https/example.com/_snapshots/20261001T100000Z/_page.html",
+			FetchedAt: &fetchedAt,
+			Metadata: &fetcher.Metadata{
+				Site:      "https://example.com",
+				NumLinks:  2,
+				Images:    1,
+				Assets:    []string{"/a.css", "/b.js"},
+				Skipped:   []fetcher.Skipped{{URL: "/c.mp4", Reason: "skipped by filter"}},
+				Failed:    []fetcher.FailedAsset{{URL: "/d.png", Reason: "unexpected status: 404 Not Found", Status: 404}},
+				FinalURL:  "https://www.example.com/",
+				Redirects: []fetcher.Redirect{{URL: "https://example.com", Status: 301}},
+			},
+		},
+		{
+			Version:   Version,
+			URL:       "https://example.org",
+			Status:    StatusFailed,
+			Error:     "failed to fetch page: request timeout of 1m0s exceeded",
+			ErrorType: "timeout",
+		},
+	}
+
+	type test struct {
+		format string
+		want   string
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully write ndjson": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				format: FormatNDJSON,
+				want: `{"version":1,"url":"https://example.com","status":"done","snapshot":"20261001T100000Z",` +
+					`"page":"https/example.com/_snapshots/20261001T100000Z/_page.html","fetched_at":"2026-10-01T10:00:00Z",` +
+					`"metadata":{"site":"https://example.com","num_links":2,"images":1,"assets":["/a.css","/b.js"],` +
+					`"last_fetch":"0001-01-01T00:00:00Z","skipped":[{"url":"/c.mp4","reason":"skipped by filter"}],` +
+					`"failed":[{"url":"/d.png","reason":"unexpected status: 404 Not Found","status":404}],` +
+					`"final_url":"https://www.example.com/","redirects":[{"url":"https://example.com","status":301}]}}
+{"version":1,"url":"https://example.org","status":"failed","error":"failed to fetch page: request timeout of 1m0s exceeded","error_type":"timeout"}
+`,
+			}
+		},
+		"Successfully write csv": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				format: FormatCSV,
+				want: `version,url,status,error,snapshot,page,archive,fetched_at,site,title,num_links,images,assets,last_fetch,skipped,failed,error_type,final_url
+1,https://example.com,done,,20261001T100000Z,https/example.com/_snapshots/20261001T100000Z/_page.html,,2026-10-01T10:00:00Z,https://example.com,,2,1,/a.css /b.js,,/c.mp4,/d.png,,https://www.example.com/
+1,https://example.org,failed,failed to fetch page: request timeout of 1m0s exceeded,,,,,,,,,,,,,timeout,
+`,
+			}
+		},
+		"Successfully write yaml": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				format: FormatYAML,
+				want: `---
+version: 1
+url: https://example.com
+status: done
+snapshot: 20261001T100000Z
+page: https/example.com/_snapshots/20261001T100000Z/_page.html
+fetched_at: "2026-10-01T10:00:00Z"
+metadata:
+    site: https://example.com
+    num_links: 2
+    images: 1
+    assets:
+        - /a.css
+        - /b.js
+    last_fetch: "0001-01-01T00:00:00Z"
+    skipped:
+        - url: /c.mp4
+          reason: skipped by filter
+    failed:
+        - url: /d.png
+          reason: 'unexpected status: 404 Not Found'
+          status: 404
+    final_url: https://www.example.com/
+    redirects:
+        - url: https://example.com
+          status: 301
+---
+version: 1
+url: https://example.org
+status: failed
+error: 'failed to fetch page: request timeout of 1m0s exceeded'
+error_type: timeout
+`,
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			var buf bytes.Buffer
+
+			writer, err := NewWriter(tt.format, &buf)
+			require.NoError(t, err)
+
+			for _, record := range records {
+				require.NoError(t, writer.Write(record))
+			}
+
+			require.NoError(t, writer.Close())
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestWriterJSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer, err := NewWriter(FormatJSON, &buf)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, "[]\n", buf.String())
+
+	buf.Reset()
+
+	writer, err = NewWriter(FormatJSON, &buf)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(Record{Version: Version, URL: "https://example.com", Status: StatusDone}))
+	require.NoError(t, writer.Write(Record{Version: Version, URL: "https://example.org", Status: StatusSkipped, Error: "skipped by filter"}))
+	require.NoError(t, writer.Close())
+
+	var got []Record
+
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, []Record{
+		{Version: Version, URL: "https://example.com", Status: StatusDone},
+		{Version: Version, URL: "https://example.org", Status: StatusSkipped, Error: "skipped by filter"},
+	}, got)
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	_, err := NewWriter(FormatText, &bytes.Buffer{})
+	assert.ErrorIs(t, err, errUnknownFormat)
+
+	_, err = NewWriter("xml", &bytes.Buffer{})
+	assert.ErrorIs(t, err, errUnknownFormat)
+}
+
+// TestSchema checks the documented schema has the fields of the records, so they're renamed together.
+func TestSchema(t *testing.T) {
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Defs       map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"$defs"`
+	}
+
+	require.NoError(t, json.Unmarshal(Schema, &schema))
+
+	assert.Equal(t, jsonFields(reflect.TypeOf(Record{})), keys(schema.Properties))
+	assert.Equal(t, jsonFields(reflect.TypeOf(fetcher.Metadata{})), keys(schema.Defs["metadata"].Properties))
+	assert.Equal(t, jsonFields(reflect.TypeOf(fetcher.Skipped{})), keys(schema.Defs["skipped"].Properties))
+	assert.Equal(t, jsonFields(reflect.TypeOf(fetcher.FailedAsset{})), keys(schema.Defs["failed"].Properties))
+	assert.Equal(t, jsonFields(reflect.TypeOf(fetcher.Redirect{})), keys(schema.Defs["redirect"].Properties))
+	assert.Equal(t, jsonFields(reflect.TypeOf(fetcher.TLSInfo{})), keys(schema.Defs["tls"].Properties))
+	assert.Equal(t, jsonFields(reflect.TypeOf(fetcher.Certificate{})), keys(schema.Defs["certificate"].Properties))
+}
+
+// jsonFields returns the sorted JSON names of the fields of the struct.
+func jsonFields(typ reflect.Type) []string {
+	fields := make([]string, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		fields = append(fields, strings.Split(typ.Field(i).Tag.Get("json"), ",")[0])
+	}
+
+	sort.Strings(fields)
+
+	return fields
+}
+
+// keys returns the sorted keys of the map.
+func keys(m map[string]json.RawMessage) []string {
+	list := make([]string, 0, len(m))
+	for key := range m {
+		list = append(list, key)
+	}
+
+	sort.Strings(list)
+
+	return list
+}
@@ -0,0 +1,437 @@
+// Package server serves the archived pages of an output directory over HTTP:
+// the saved files, the files inside the zip archives, an index of the sites and their snapshots,
+// and the metadata of every snapshot.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/fetcher"
+	"github.com/moemoe89/fetch/pkg/snapshot"
+	"github.com/moemoe89/fetch/pkg/storage"
+)
+
+// Routes of the server.
+const (
+	// filesRoute serves the files of the storage as is, e.g. `/files/https/example.com/_snapshots/<id>/_page.html`.
+	// The links of the saved pages are relative, so their assets are served from `/files/_store/...`.
+	filesRoute = "/files/"
+	// zipRoute lists an archive, e.g. `/zip/<dir>/_page.zip`, and serves its entries read directly from the zip,
+	// e.g. `/zip/<dir>/_page.zip/<dir>/_page.html`.
+	zipRoute = "/zip/"
+	// viewRoute redirects the original URL to the page of a snapshot, e.g. `/view?url=https://example.com&at=latest`.
+	viewRoute = "/view"
+	// metadataRoute shows the metadata of a snapshot, e.g. `/metadata/https/example.com/_snapshots/<id>`.
+	metadataRoute = "/metadata/"
+)
+
+// zipExt is the extension of the archives served from zipRoute.
+const zipExt = ".zip"
+
+// storeDir is the directory of the asset store in the output directory, the only files served outside the snapshots.
+const storeDir = "_store"
+
+// Server serves the archived pages saved in the storage.
+type Server struct {
+	storage storage.Storage
+	history *snapshot.History
+	mux     *http.ServeMux
+}
+
+// New returns a Server serving the pages saved in the storage.
+func New(s storage.Storage) *Server {
+	server := &Server{
+		storage: s,
+		history: snapshot.New(s),
+		mux:     http.NewServeMux(),
+	}
+
+	server.mux.HandleFunc("/", server.handleIndex)
+	server.mux.HandleFunc(filesRoute, server.handleFile)
+	server.mux.HandleFunc(zipRoute, server.handleZip)
+	server.mux.HandleFunc(viewRoute, server.handleView)
+	server.mux.HandleFunc(metadataRoute, server.handleMetadata)
+
+	return server
+}
+
+// ServeHTTP implements http.Handler, only GET and HEAD requests are allowed.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// indexSite is a site listed on the index page.
+type indexSite struct {
+	URL       string
+	Snapshots []indexSnapshot
+}
+
+// indexSnapshot is a snapshot listed on the index page with the links to its files.
+type indexSnapshot struct {
+	ID        string
+	FetchedAt time.Time
+	Page      string
+	Archive   string
+	Metadata  string
+}
+
+// handleIndex lists all archived sites with their snapshots, from the latest to the oldest.
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		s.handleOriginal(w, r)
+
+		return
+	}
+
+	indexes, err := s.history.Indexes()
+	if err != nil {
+		s.serverError(w, err)
+
+		return
+	}
+
+	sites := make([]indexSite, 0, len(indexes))
+
+	for _, index := range indexes {
+		site := indexSite{URL: index.URL}
+
+		for i := len(index.Snapshots) - 1; i >= 0; i-- {
+			snap, err := s.indexSnapshot(index.Snapshots[i])
+			if err != nil {
+				s.serverError(w, err)
+
+				return
+			}
+
+			site.Snapshots = append(site.Snapshots, snap)
+		}
+
+		sites = append(sites, site)
+	}
+
+	s.render(w, indexTemplate, sites)
+}
+
+// indexSnapshot links the files saved in the snapshot.
+func (s *Server) indexSnapshot(snap snapshot.Snapshot) (indexSnapshot, error) {
+	result := indexSnapshot{ID: snap.ID, FetchedAt: snap.FetchedAt}
+
+	files, err := s.history.Files(snap)
+	if err != nil {
+		return result, err
+	}
+
+	for _, name := range files {
+		switch path.Base(name) {
+		case snapshot.PageFilename:
+			result.Page = filesRoute + escapePath(name)
+		case snapshot.ZipFilename:
+			result.Archive = zipRoute + escapePath(name) + "/" + escapePath(path.Join(snap.Dir, snapshot.PageFilename))
+		case fetcher.MetadataFilename:
+			result.Metadata = metadataRoute + escapePath(snap.Dir)
+		}
+	}
+
+	return result, nil
+}
+
+// handleOriginal maps a root-relative link of a served page, e.g. `/docs/`, to its original URL
+// resolved against the URL of the referring page, and redirects to the latest snapshot of that URL.
+func (s *Server) handleOriginal(w http.ResponseWriter, r *http.Request) {
+	referer, err := url.Parse(r.Referer())
+	if err != nil || !strings.HasPrefix(referer.Path, filesRoute) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	// The referring page is saved as `<url dir>/_snapshots/<id>/_page.html`.
+	name := strings.TrimPrefix(referer.Path, filesRoute)
+
+	i := strings.Index(name, "/"+snapshot.Dir+"/")
+	if i < 0 {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	body, err := s.storage.ReadFile(path.Join(name[:i], snapshot.IndexFilename))
+	if err != nil {
+		s.fileError(w, r, err)
+
+		return
+	}
+
+	var index snapshot.Index
+
+	if err := json.Unmarshal(body, &index); err != nil {
+		s.serverError(w, fmt.Errorf("failed to unmarshal index: %w", err))
+
+		return
+	}
+
+	base, err := url.Parse(index.URL)
+	if err != nil {
+		s.serverError(w, fmt.Errorf("failed to parse url: %w", err))
+
+		return
+	}
+
+	original := base.ResolveReference(&url.URL{Path: r.URL.Path, RawQuery: r.URL.RawQuery})
+
+	snap, err := s.history.Find(original.String(), snapshot.Latest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s isn't archived", original), http.StatusNotFound)
+
+		return
+	}
+
+	http.Redirect(w, r, filesRoute+escapePath(path.Join(snap.Dir, snapshot.PageFilename)), http.StatusFound)
+}
+
+// handleFile serves a file of the storage.
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.storageName(w, r, filesRoute)
+	if !ok {
+		return
+	}
+
+	body, err := s.storage.ReadFile(name)
+	if err != nil {
+		s.fileError(w, r, err)
+
+		return
+	}
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(body))
+}
+
+// handleZip lists the entries of the archive, or serves one of its entries.
+func (s *Server) handleZip(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.storageName(w, r, zipRoute)
+	if !ok {
+		return
+	}
+
+	zipName, entryName := name, ""
+
+	if i := strings.Index(name, zipExt+"/"); i >= 0 {
+		zipName, entryName = name[:i+len(zipExt)], name[i+len(zipExt)+1:]
+	}
+
+	if !strings.HasSuffix(zipName, zipExt) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	body, err := s.storage.ReadFile(zipName)
+	if err != nil {
+		s.fileError(w, r, err)
+
+		return
+	}
+
+	archive, err := fetcher.NewArchive(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		s.serverError(w, err)
+
+		return
+	}
+
+	if entryName == "" {
+		s.listArchive(w, zipName, archive)
+
+		return
+	}
+
+	file, err := archive.Open(entryName)
+	if err != nil {
+		s.fileError(w, r, err)
+
+		return
+	}
+
+	defer func() { _ = file.Close() }()
+
+	entry, err := io.ReadAll(file)
+	if err != nil {
+		s.serverError(w, err)
+
+		return
+	}
+
+	http.ServeContent(w, r, entryName, time.Time{}, bytes.NewReader(entry))
+}
+
+// archiveEntry is an archive entry listed with its link.
+type archiveEntry struct {
+	fetcher.ArchiveEntry
+	Link string
+}
+
+// listArchive lists the entries of the archive with their verification status.
+func (s *Server) listArchive(w http.ResponseWriter, zipName string, archive *fetcher.Archive) {
+	entries, err := archive.Entries()
+	if err != nil {
+		s.serverError(w, err)
+
+		return
+	}
+
+	data := struct {
+		Name    string
+		Entries []archiveEntry
+	}{Name: zipName}
+
+	for _, entry := range entries {
+		link := ""
+		if entry.Status != fetcher.EntryStatusMissing {
+			link = zipRoute + escapePath(zipName) + "/" + escapePath(entry.Name)
+		}
+
+		data.Entries = append(data.Entries, archiveEntry{ArchiveEntry: entry, Link: link})
+	}
+
+	s.render(w, archiveTemplate, data)
+}
+
+// handleView redirects the original URL to the saved page of the snapshot selected by `at`, the latest by default.
+func (s *Server) handleView(w http.ResponseWriter, r *http.Request) {
+	pageURL := r.URL.Query().Get("url")
+	if pageURL == "" {
+		http.Error(w, "missing url parameter", http.StatusBadRequest)
+
+		return
+	}
+
+	at := r.URL.Query().Get("at")
+	if at == "" {
+		at = snapshot.Latest
+	}
+
+	snap, err := s.history.Find(pageURL, at)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+
+		return
+	}
+
+	http.Redirect(w, r, filesRoute+escapePath(path.Join(snap.Dir, snapshot.PageFilename)), http.StatusFound)
+}
+
+// handleMetadata shows the metadata JSON of the snapshot directory.
+func (s *Server) handleMetadata(w http.ResponseWriter, r *http.Request) {
+	dir, ok := s.storageName(w, r, metadataRoute)
+	if !ok {
+		return
+	}
+
+	body, err := s.storage.ReadFile(path.Join(dir, fetcher.MetadataFilename))
+	if err != nil {
+		s.fileError(w, r, err)
+
+		return
+	}
+
+	var metadata *fetcher.Metadata
+
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		s.serverError(w, fmt.Errorf("failed to unmarshal metadata: %w", err))
+
+		return
+	}
+
+	data := struct {
+		Dir      string
+		Page     string
+		Metadata *fetcher.Metadata
+	}{
+		Dir:      dir,
+		Page:     filesRoute + escapePath(path.Join(dir, snapshot.PageFilename)),
+		Metadata: metadata,
+	}
+
+	s.render(w, metadataTemplate, data)
+}
+
+// storageName returns the storage name after the route prefix of the request path.
+// Only the files of the snapshots and of the asset store are served, never the other files of the output directory,
+// e.g. a job journal under `.fetch`, which the scripts of the archived pages could read from the same origin.
+// Names escaping the storage root are rejected, it writes the error response and returns false.
+func (s *Server) storageName(w http.ResponseWriter, r *http.Request, route string) (string, bool) {
+	name, err := storage.CleanName(strings.TrimPrefix(r.URL.Path, route))
+	if err != nil || !servable(name) {
+		http.NotFound(w, r)
+
+		return "", false
+	}
+
+	return name, true
+}
+
+// servable reports whether the storage name is in a snapshot directory or in the asset store,
+// and has no hidden element.
+func servable(name string) bool {
+	elements := strings.Split(name, "/")
+
+	for _, element := range elements {
+		if strings.HasPrefix(element, ".") {
+			return false
+		}
+	}
+
+	if elements[0] == storeDir {
+		return len(elements) > 1
+	}
+
+	for i, element := range elements {
+		// The snapshot directory itself is `<url dir>/_snapshots/<id>`.
+		if element == snapshot.Dir && i > 0 && i+1 < len(elements) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fileError writes 404 if the file doesn't exist, 500 otherwise.
+func (s *Server) fileError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, storage.ErrNotExist) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	s.serverError(w, err)
+}
+
+// serverError logs the error and writes 500.
+func (s *Server) serverError(w http.ResponseWriter, err error) {
+	log.Println(err)
+
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// escapePath escapes every element of the slash separated path for a URL path,
+// e.g. `https/example.com/a~q%3D1` as `https/example.com/a~q%253D1`.
+func escapePath(name string) string {
+	return (&url.URL{Path: name}).EscapedPath()
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/fetcher"
+	"github.com/moemoe89/fetch/pkg/snapshot"
+	"github.com/moemoe89/fetch/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveTestSnapshot saves a page with its metadata and archive as a snapshot of the URL.
+func saveTestSnapshot(t *testing.T, s storage.Storage, url, page string) snapshot.Snapshot {
+	t.Helper()
+
+	history := snapshot.New(s)
+
+	snap, _, err := history.Begin(url, time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	pageFile := path.Join(snap.Dir, snapshot.PageFilename)
+	metadataFile := path.Join(snap.Dir, fetcher.MetadataFilename)
+	styleFile := "_store/sha256/ab/abcd.css"
+
+	require.NoError(t, s.WriteFile(pageFile, []byte(page)))
+	require.NoError(t, s.WriteFile(styleFile, []byte("body{}")))
+
+	metadata, err := json.Marshal(&fetcher.Metadata{
+		Site:         url,
+		Title:        "Example",
+		Assets:       []string{"/style.css"},
+		AssetDigests: map[string]string{"/style.css": "abcd"},
+		Checksums:    map[string]string{pageFile: fetcher.Checksum([]byte(page))},
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.WriteFile(metadataFile, metadata))
+
+	client, err := fetcher.New(fetcher.WithStorage(s))
+	require.NoError(t, err)
+	require.NoError(t, client.Zip(context.Background(), path.Join(snap.Dir, snapshot.ZipFilename), []string{pageFile, metadataFile, styleFile}, nil))
+
+	require.NoError(t, history.Commit(url, snap))
+
+	return snap
+}
+
+func TestServer(t *testing.T) {
+	s := storage.NewMemory()
+
+	// The journal of the CLI lives in the default output directory, with the headers of the URLs.
+	require.NoError(t, s.WriteFile(".fetch/jobs/0123456789abcdef.jsonl", []byte(`{"headers":{"Authorization":"secret"}}`)))
+	require.NoError(t, s.WriteFile("notes.txt", []byte("notes")))
+
+	snap := saveTestSnapshot(t, s, "https://example.com/", `<html><body><a href="/docs/">docs</a></body></html>`)
+	docs := saveTestSnapshot(t, s, "https://example.com/docs/", `<html><body>docs</body></html>`)
+
+	require.NoError(t, s.WriteFile(snap.Dir+"/.secret", []byte("secret")))
+
+	pageLink := "/files/" + snap.Dir + "/_page.html"
+	zipLink := "/zip/" + snap.Dir + "/_page.zip"
+
+	type args struct {
+		method  string
+		target  string
+		referer string
+	}
+
+	type test struct {
+		args         args
+		wantStatus   int
+		wantBody     string
+		wantLocation string
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully list sites and snapshots": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{target: "/"},
+				wantStatus: http.StatusOK,
+				wantBody:   `<a href="` + pageLink + `">page</a>`,
+			}
+		},
+		"Successfully serve file": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{target: "/files/_store/sha256/ab/abcd.css"},
+				wantStatus: http.StatusOK,
+				wantBody:   "body{}",
+			}
+		},
+		"Successfully list archive": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{target: zipLink},
+				wantStatus: http.StatusOK,
+				wantBody:   `<td class="ok">ok</td>`,
+			}
+		},
+		"Successfully serve archive entry": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{target: zipLink + "/_store/sha256/ab/abcd.css"},
+				wantStatus: http.StatusOK,
+				wantBody:   "body{}",
+			}
+		},
+		"Successfully show metadata": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:       args{target: "/metadata/" + snap.Dir},
+				wantStatus: http.StatusOK,
+				wantBody:   "<tr><td><code>/style.css</code></td><td><code>abcd</code></td></tr>",
+			}
+		},
+		"Successfully redirect original URL": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:         args{target: "/view?url=https://example.com/"},
+				wantStatus:   http.StatusFound,
+				wantLocation: pageLink,
+			}
+		},
+		"Successfully redirect root-relative link of a served page": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:         args{target: "/docs/", referer: "http://localhost" + pageLink},
+				wantStatus:   http.StatusFound,
+				wantLocation: "/files/" + docs.Dir + "/_page.html",
+			}
+		},
+		"Failed root-relative link without referer": func(t *testing.T) test {
+			t.Helper()
+
+			return test{args: args{target: "/docs/"}, wantStatus: http.StatusNotFound}
+		},
+		"Failed view not archived URL": func(t *testing.T) test {
+			t.Helper()
+
+			return test{args: args{target: "/view?url=https://other.com/"}, wantStatus: http.StatusNotFound}
+		},
+		"Failed missing file": func(t *testing.T) test {
+			t.Helper()
+
+			return test{args: args{target: "/files/missing.html"}, wantStatus: http.StatusNotFound}
+		},
+		"Failed serve job journal": func(t *testing.T) test {
+			t.Helper()
+
+			return test{args: args{target: "/files/.fetch/jobs/0123456789abcdef.jsonl"}, wantStatus: http.StatusNotFound}
+		},
+		"Failed serve file outside snapshots and store": func(t *testing.T) test {
+			t.Helper()
+
+			return test{args: args{target: "/files/notes.txt"}, wantStatus: http.StatusNotFound}
+		},
+		"Failed serve hidden file of snapshot": func(t *testing.T) test {
+			t.Helper()
+
+			return test{args: args{target: "/files/" + snap.Dir + "/.secret"}, wantStatus: http.StatusNotFound}
+		},
+		"Failed missing archive entry": func(t *testing.T) test {
+			t.Helper()
+
+			return test{args: args{target: zipLink + "/missing.html"}, wantStatus: http.StatusNotFound}
+		},
+		"Failed method not allowed": func(t *testing.T) test {
+			t.Helper()
+
+			return test{args: args{method: http.MethodPost, target: "/"}, wantStatus: http.StatusMethodNotAllowed}
+		},
+	}
+
+	server := New(s)
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			method := tt.args.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req := httptest.NewRequest(method, tt.args.target, nil)
+			if tt.args.referer != "" {
+				req.Header.Set("Referer", tt.args.referer)
+			}
+
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+			assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+)
+
+// layout is the common layout of the pages, every page defines its `title` and `content`.
+const layout = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{template "title" .}} - fetch</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+code { font-size: 0.9em; word-break: break-all; }
+.mismatch, .missing { color: #b00; }
+</style>
+</head>
+<body>
+<p><a href="/">All sites</a></p>
+{{template "content" .}}
+</body>
+</html>
+`
+
+// indexTemplate lists the archived sites and their snapshots.
+var indexTemplate = newTemplate(`
+{{define "title"}}Archived sites{{end}}
+{{define "content"}}
+<h1>Archived sites</h1>
+{{- if not .}}
+<p>No page is archived yet.</p>
+{{- end}}
+{{- range .}}
+<h2><a href="/view?url={{.URL}}">{{.URL}}</a></h2>
+<table>
+<tr><th>Snapshot</th><th>Fetched at</th><th>Page</th><th>Archive</th><th>Metadata</th></tr>
+{{- range .Snapshots}}
+<tr>
+<td>{{.ID}}</td>
+<td>{{.FetchedAt.Format "Mon Jan 02 2006 15:04 MST"}}</td>
+<td>{{with .Page}}<a href="{{.}}">page</a>{{end}}</td>
+<td>{{with .Archive}}<a href="{{.}}">archive</a>{{end}}</td>
+<td>{{with .Metadata}}<a href="{{.}}">metadata</a>{{end}}</td>
+</tr>
+{{- end}}
+</table>
+{{- end}}
+{{end}}
+`)
+
+// archiveTemplate lists the entries of an archive.
+var archiveTemplate = newTemplate(`
+{{define "title"}}{{.Name}}{{end}}
+{{define "content"}}
+<h1><code>{{.Name}}</code></h1>
+<table>
+<tr><th>Name</th><th>Size</th><th>Compressed</th><th>Status</th></tr>
+{{- range .Entries}}
+<tr>
+<td>{{if .Link}}<a href="{{.Link}}"><code>{{.Name}}</code></a>{{else}}<code>{{.Name}}</code>{{end}}</td>
+<td>{{.Size}}</td>
+<td>{{.CompressedSize}}</td>
+<td class="{{.Status}}">{{.Status}}</td>
+</tr>
+{{- end}}
+</table>
+{{end}}
+`)
+
+// metadataTemplate shows the metadata of a snapshot.
+var metadataTemplate = newTemplate(`
+{{define "title"}}{{.Metadata.Site}}{{end}}
+{{define "content"}}
+<h1>{{.Metadata.Site}}</h1>
+<p><a href="{{.Page}}">Open the page</a></p>
+<table>
+<tr><th>Title</th><td>{{.Metadata.Title}}</td></tr>
+<tr><th>Links</th><td>{{.Metadata.NumLinks}}</td></tr>
+<tr><th>Images</th><td>{{.Metadata.Images}}</td></tr>
+<tr><th>Last fetch</th><td>{{if .Metadata.LastFetch.IsZero}}-{{else}}{{.Metadata.LastFetch.Format "Mon Jan 02 2006 15:04 MST"}}{{end}}</td></tr>
+<tr><th>Snapshot</th><td><code>{{.Dir}}</code></td></tr>
+</table>
+{{- with .Metadata.Assets}}
+<h2>Assets</h2>
+<table>
+<tr><th>URL</th><th>SHA-256</th></tr>
+{{- range .}}
+<tr><td><code>{{.}}</code></td><td><code>{{index $.Metadata.AssetDigests .}}</code></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with .Metadata.Links}}
+<h2>Anchors</h2>
+<ul>
+{{- range .}}
+<li><code>{{.}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- with .Metadata.Checksums}}
+<h2>Checksums</h2>
+<table>
+<tr><th>Entry</th><th>SHA-256</th></tr>
+{{- range $name, $checksum := .}}
+<tr><td><code>{{$name}}</code></td><td><code>{{$checksum}}</code></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{end}}
+`)
+
+// newTemplate parses the page with the common layout.
+func newTemplate(page string) *template.Template {
+	return template.Must(template.Must(template.New("layout").Parse(layout)).Parse(page))
+}
+
+// render executes the template into a buffer first, so a failing template doesn't send a partial page.
+func (s *Server) render(w http.ResponseWriter, tmpl *template.Template, data interface{}) {
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, data); err != nil {
+		s.serverError(w, err)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	_, _ = buf.WriteTo(w)
+}
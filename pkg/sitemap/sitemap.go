@@ -0,0 +1,282 @@
+// Package sitemap reads the URLs of a site from its XML sitemaps, discovered from robots.txt if needed,
+// so a whole site can be archived without following its links.
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// RobotsFilename is the path of the robots.txt file listing the sitemaps of a site.
+	RobotsFilename = "/robots.txt"
+	// DefaultFilename is the path of the sitemap of a site when robots.txt doesn't list any.
+	DefaultFilename = "/sitemap.xml"
+	// maxSize is the maximum size of an uncompressed sitemap, 50MB as defined by the sitemap protocol.
+	maxSize = 50 * 1024 * 1024
+	// maxSitemaps is the maximum number of sitemaps read from the sitemap indexes of a site.
+	maxSitemaps = 1000
+)
+
+var (
+	// errInvalidSitemap represents an error message when the sitemap isn't a urlset or a sitemapindex XML document.
+	errInvalidSitemap = errors.New("invalid sitemap")
+	// errSitemapTooLarge represents an error message when the uncompressed sitemap is larger than maxSize.
+	errSitemapTooLarge = errors.New("sitemap is too large")
+	// errTooManySitemaps represents an error message when the sitemap indexes list more than maxSitemaps sitemaps.
+	errTooManySitemaps = errors.New("too many sitemaps")
+)
+
+// gzipMagic are the first bytes of a gzip file, gzipped sitemaps are detected by their content.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// lastModLayouts are the W3C datetime formats of the lastmod element.
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// PageFetcher fetches the sitemaps, fetcher.Fetcher implements it.
+type PageFetcher interface {
+	FetchPage(ctx context.Context, url string) ([]byte, error)
+}
+
+// Entry is a URL listed in a sitemap.
+type Entry struct {
+	URL string
+	// LastMod is the time the page was last modified, zero if the sitemap doesn't have it.
+	LastMod time.Time
+}
+
+// Filter selects the entries of the sitemaps, the zero value selects all of them.
+type Filter struct {
+	// Since selects the entries modified at or after the time, the entries without lastmod are always selected.
+	Since time.Time
+	// Match selects the entries whose URL matches any of the patterns.
+	Match []*regexp.Regexp
+}
+
+// location is a `url` element of a urlset, or a `sitemap` element of a sitemapindex.
+type location struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// document is a urlset or a sitemapindex XML document.
+type document struct {
+	XMLName  xml.Name
+	URLs     []location `xml:"url"`
+	Sitemaps []location `xml:"sitemap"`
+}
+
+// Read returns the entries of the sitemap at `rawURL` selected by the filter, in the order of the sitemaps.
+// The sitemaps of a sitemap index are read too, and gzipped sitemaps are uncompressed.
+// If `rawURL` is the root of a site or its robots.txt, the sitemaps are discovered from the `Sitemap:` lines
+// of robots.txt, or /sitemap.xml is read if it doesn't list any.
+func Read(ctx context.Context, f PageFetcher, rawURL string, filter Filter) ([]Entry, error) {
+	sitemaps, err := discover(ctx, f, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reader{fetcher: f, filter: filter, seen: make(map[string]bool)}
+
+	for _, sitemap := range sitemaps {
+		if err := r.read(ctx, sitemap); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.entries, nil
+}
+
+// reader reads the sitemaps and collects their entries.
+type reader struct {
+	fetcher  PageFetcher
+	filter   Filter
+	entries  []Entry
+	seen     map[string]bool
+	sitemaps int
+}
+
+// read reads the sitemap, or the sitemaps of a sitemap index.
+func (r *reader) read(ctx context.Context, sitemapURL string) error {
+	if r.seen[sitemapURL] {
+		return nil
+	}
+
+	r.seen[sitemapURL] = true
+
+	r.sitemaps++
+	if r.sitemaps > maxSitemaps {
+		return fmt.Errorf("%w: more than %d", errTooManySitemaps, maxSitemaps)
+	}
+
+	body, err := r.fetcher.FetchPage(ctx, sitemapURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch sitemap: %s: %w", sitemapURL, err)
+	}
+
+	doc, err := parse(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse sitemap: %s: %w", sitemapURL, err)
+	}
+
+	for _, sitemap := range doc.Sitemaps {
+		loc := strings.TrimSpace(sitemap.Loc)
+
+		// A sitemap not modified since the time doesn't list any modified page.
+		if loc == "" || !r.filter.since(sitemap.LastMod) {
+			continue
+		}
+
+		if err := r.read(ctx, loc); err != nil {
+			return err
+		}
+	}
+
+	for _, location := range doc.URLs {
+		entry := Entry{URL: strings.TrimSpace(location.Loc), LastMod: parseLastMod(location.LastMod)}
+
+		if entry.URL == "" || r.seen[entry.URL] || !r.filter.selects(entry) {
+			continue
+		}
+
+		r.seen[entry.URL] = true
+
+		r.entries = append(r.entries, entry)
+	}
+
+	return nil
+}
+
+// selects reports whether the filter selects the entry.
+func (f Filter) selects(entry Entry) bool {
+	if !f.Since.IsZero() && !entry.LastMod.IsZero() && entry.LastMod.Before(f.Since) {
+		return false
+	}
+
+	if len(f.Match) == 0 {
+		return true
+	}
+
+	for _, pattern := range f.Match {
+		if pattern.MatchString(entry.URL) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// since reports whether the lastmod is at or after the Since time of the filter, or unknown.
+func (f Filter) since(lastMod string) bool {
+	modified := parseLastMod(lastMod)
+
+	return f.Since.IsZero() || modified.IsZero() || !modified.Before(f.Since)
+}
+
+// discover returns the sitemaps of the URL: the sitemaps listed in robots.txt for the root of a site or its robots.txt,
+// /sitemap.xml if robots.txt doesn't list any, otherwise the URL itself.
+func discover(ctx context.Context, f PageFetcher, rawURL string) ([]string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %s: %w", rawURL, err)
+	}
+
+	if parsed.Path != "" && parsed.Path != "/" && parsed.Path != RobotsFilename {
+		return []string{rawURL}, nil
+	}
+
+	root := &url.URL{Scheme: parsed.Scheme, Host: parsed.Host}
+
+	robotsURL := root.String() + RobotsFilename
+
+	body, err := f.FetchPage(ctx, robotsURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch robots.txt: %s: %w", robotsURL, err)
+	}
+
+	var sitemaps []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "sitemap") {
+			continue
+		}
+
+		// The sitemap URL may be relative to the site.
+		sitemap, err := root.Parse(strings.TrimSpace(value))
+		if err == nil {
+			sitemaps = append(sitemaps, sitemap.String())
+		}
+	}
+
+	if len(sitemaps) == 0 {
+		sitemaps = append(sitemaps, root.String()+DefaultFilename)
+	}
+
+	return sitemaps, nil
+}
+
+// parse parses the sitemap, gzipped or not.
+func parse(body []byte) (*document, error) {
+	var reader io.Reader = bytes.NewReader(body)
+
+	if bytes.HasPrefix(body, gzipMagic) {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip: %w", err)
+		}
+
+		defer func() { _ = gzipReader.Close() }()
+
+		reader = gzipReader
+	}
+
+	limited := &io.LimitedReader{R: reader, N: maxSize + 1}
+
+	var doc document
+
+	if err := xml.NewDecoder(limited).Decode(&doc); err != nil {
+		if limited.N <= 0 {
+			return nil, fmt.Errorf("%w: larger than %d bytes", errSitemapTooLarge, maxSize)
+		}
+
+		return nil, fmt.Errorf("%w: %v", errInvalidSitemap, err)
+	}
+
+	if doc.XMLName.Local != "urlset" && doc.XMLName.Local != "sitemapindex" {
+		return nil, fmt.Errorf("%w: unexpected root element %s", errInvalidSitemap, doc.XMLName.Local)
+	}
+
+	return &doc, nil
+}
+
+// parseLastMod parses the W3C datetime of the lastmod element, the zero time is returned if it's invalid.
+func parseLastMod(value string) time.Time {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range lastModLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed
+		}
+	}
+
+	return time.Time{}
+}
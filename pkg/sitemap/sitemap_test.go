@@ -0,0 +1,159 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFetcher serves the pages of a site from memory.
+type fakeFetcher map[string][]byte
+
+func (f fakeFetcher) FetchPage(ctx context.Context, url string) ([]byte, error) {
+	body, ok := f[url]
+	if !ok {
+		return []byte("<html><body>Not Found</body></html>"), nil
+	}
+
+	return body, nil
+}
+
+// gzipped compresses the body.
+func gzipped(t *testing.T, body string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	site := fakeFetcher{
+		"https://example.com/robots.txt": []byte("User-agent: *\nDisallow: /private\nSitemap: /sitemap_index.xml\n"),
+		"https://example.com/sitemap_index.xml": []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/pages.xml</loc><lastmod>2026-10-01</lastmod></sitemap>
+  <sitemap><loc>https://example.com/posts.xml.gz</loc></sitemap>
+  <sitemap><loc>https://example.com/archive.xml</loc><lastmod>2020-01-01T00:00:00Z</lastmod></sitemap>
+</sitemapindex>`),
+		"https://example.com/pages.xml": []byte(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://example.com/ </loc><lastmod>2026-10-01T10:00:00+00:00</lastmod></url>
+  <url><loc>https://example.com/about</loc></url>
+</urlset>`),
+		"https://example.com/archive.xml": []byte(`<urlset><url><loc>https://example.com/old</loc><lastmod>2020-01-01</lastmod></url></urlset>`),
+	}
+
+	site["https://example.com/posts.xml.gz"] = gzipped(t, `<urlset>
+  <url><loc>https://example.com/posts/1</loc><lastmod>2026-09-01</lastmod></url>
+  <url><loc>https://example.com/posts/2</loc><lastmod>2026-10-15T08:30Z</lastmod></url>
+  <url><loc>https://example.com/about</loc></url>
+</urlset>`)
+
+	type test struct {
+		url     string
+		filter  Filter
+		want    []string
+		wantErr error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully read the sitemaps listed in robots.txt": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				url: "https://example.com",
+				want: []string{
+					"https://example.com/", "https://example.com/about",
+					"https://example.com/posts/1", "https://example.com/posts/2",
+					"https://example.com/old",
+				},
+			}
+		},
+		"Successfully read the sitemaps modified since a time": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				url:    "https://example.com/robots.txt",
+				filter: Filter{Since: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+				want:   []string{"https://example.com/", "https://example.com/about", "https://example.com/posts/2"},
+			}
+		},
+		"Successfully read the URLs matching a pattern": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				url:    "https://example.com/posts.xml.gz",
+				filter: Filter{Match: []*regexp.Regexp{regexp.MustCompile(`/posts/`)}},
+				want:   []string{"https://example.com/posts/1", "https://example.com/posts/2"},
+			}
+		},
+		"Failed read a page which isn't a sitemap": func(t *testing.T) test {
+			t.Helper()
+
+			return test{url: "https://example.com/missing.xml", wantErr: errInvalidSitemap}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			entries, err := Read(context.Background(), site, tt.url, tt.filter)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			got := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				got = append(got, entry.URL)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadDefaultSitemap(t *testing.T) {
+	site := fakeFetcher{
+		"https://example.com/sitemap.xml": []byte(`<urlset><url><loc>https://example.com/a</loc></url></urlset>`),
+	}
+
+	entries, err := Read(context.Background(), site, "https://example.com/", Filter{})
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{{URL: "https://example.com/a"}}, entries)
+}
+
+func TestReadTooManySitemaps(t *testing.T) {
+	site := fakeFetcher{}
+
+	// Every sitemap index lists the next one.
+	for i := 0; i <= maxSitemaps; i++ {
+		site[fmt.Sprintf("https://example.com/%d.xml", i)] = []byte(fmt.Sprintf(
+			`<sitemapindex><sitemap><loc>https://example.com/%d.xml</loc></sitemap></sitemapindex>`, i+1))
+	}
+
+	_, err := Read(context.Background(), site, "https://example.com/0.xml", Filter{})
+	assert.ErrorIs(t, err, errTooManySitemaps)
+}
+
+func TestParseLastMod(t *testing.T) {
+	assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), parseLastMod("2026-10-01"))
+	assert.Equal(t, time.Date(2026, 10, 1, 8, 30, 0, 0, time.UTC), parseLastMod("2026-10-01T10:30+02:00").UTC())
+	assert.True(t, parseLastMod("yesterday").IsZero())
+}
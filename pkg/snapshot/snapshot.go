@@ -0,0 +1,417 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/storage"
+	"github.com/moemoe89/fetch/pkg/utils"
+)
+
+const (
+	// IndexFilename is the filename of the index of all snapshots in the URL directory.
+	IndexFilename = "_index.json"
+	// Dir is the directory of the snapshots in the URL directory.
+	Dir = "_snapshots"
+	// PageFilename is the filename of the saved HTML page in the snapshot directory.
+	PageFilename = "_page.html"
+	// ZipFilename is the filename of the archive in the snapshot directory.
+	ZipFilename = "_page.zip"
+	// IDFormat is the time format of the snapshot ID, the ISO 8601 basic format is safe for every filesystem.
+	IDFormat = "20060102T150405Z"
+	// Latest selects the latest snapshot in Find.
+	Latest = "latest"
+	// Previous selects the snapshot before the latest one in Find.
+	Previous = "previous"
+)
+
+var (
+	// errSnapshotNotFound represents an error message when no snapshot matches.
+	errSnapshotNotFound = errors.New("snapshot not found")
+	// errInvalidAge represents an error message when the age can't be parsed.
+	errInvalidAge = errors.New("invalid age")
+)
+
+// Snapshot is a single fetch of a URL, its files are saved in Dir.
+type Snapshot struct {
+	ID        string    `json:"id"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Dir       string    `json:"dir"`
+	// ContentHash is the hash of the page compared by the watch mode to detect changes.
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
+// Index lists all snapshots of a URL sorted from the oldest to the latest.
+type Index struct {
+	URL       string     `json:"url"`
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// Retention is the policy of the snapshots to keep, zero values keep everything.
+// The latest snapshot is always kept.
+type Retention struct {
+	// Keep is the number of the latest snapshots to keep.
+	Keep int
+	// KeepFor is the maximum age of the snapshots to keep.
+	KeepFor time.Duration
+}
+
+// History keeps the snapshots of the URLs in the storage as `<url dir>/_snapshots/<id>/`
+// with an index in `<url dir>/_index.json`.
+//
+// A storage must have a single writer: the reserved IDs and the updates of the indexes are only serialized
+// within the process, two processes saving the same URL can get the same ID or lose a snapshot from the index.
+type History struct {
+	storage storage.Storage
+	mutex   sync.Mutex
+	// pending are the directories of the snapshots begun but not committed or discarded yet,
+	// so concurrent fetches of a URL in the same second get different IDs.
+	pending map[string]bool
+}
+
+// New returns a History saving the snapshots in the storage.
+func New(s storage.Storage) *History {
+	return &History{storage: s, pending: make(map[string]bool)}
+}
+
+// Begin returns a new snapshot of the URL fetched at the given time and the latest committed snapshot, if any.
+// The files of the snapshot must be saved in its Dir before calling Commit, the ID stays reserved until Commit or Discard.
+func (h *History) Begin(url string, fetchedAt time.Time) (Snapshot, *Snapshot, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	index, err := h.Index(url)
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+
+	urlDir, err := utils.URLToPath(url)
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+
+	fetchedAt = fetchedAt.UTC()
+
+	id := fetchedAt.Format(IDFormat)
+
+	// Snapshots fetched in the same second get a counter suffix.
+	for i := 2; index.find(id) >= 0 || h.pending[path.Join(urlDir, Dir, id)]; i++ {
+		id = fetchedAt.Format(IDFormat) + "-" + strconv.Itoa(i)
+	}
+
+	snapshot := Snapshot{ID: id, FetchedAt: fetchedAt, Dir: path.Join(urlDir, Dir, id)}
+
+	h.pending[snapshot.Dir] = true
+
+	var previous *Snapshot
+
+	if len(index.Snapshots) > 0 {
+		latest := index.Snapshots[len(index.Snapshots)-1]
+		previous = &latest
+	}
+
+	return snapshot, previous, nil
+}
+
+// Commit adds the snapshot to the index of the URL.
+func (h *History) Commit(url string, snapshot Snapshot) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	index, err := h.Index(url)
+	if err != nil {
+		return err
+	}
+
+	if index.find(snapshot.ID) < 0 {
+		index.Snapshots = append(index.Snapshots, snapshot)
+	}
+
+	if err := h.saveIndex(index); err != nil {
+		return err
+	}
+
+	delete(h.pending, snapshot.Dir)
+
+	return nil
+}
+
+// Index reads the index of the URL, an empty index is returned if the URL has no snapshot yet.
+func (h *History) Index(url string) (*Index, error) {
+	indexFile, err := indexPath(url)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := h.storage.ReadFile(indexFile)
+	if errors.Is(err, storage.ErrNotExist) {
+		return &Index{URL: url}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var index *Index
+
+	if err := json.Unmarshal(body, &index); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal index: %w", err)
+	}
+
+	sort.SliceStable(index.Snapshots, func(i, j int) bool {
+		return index.Snapshots[i].FetchedAt.Before(index.Snapshots[j].FetchedAt)
+	})
+
+	return index, nil
+}
+
+// Indexes reads the indexes of all URLs in the storage, sorted by URL.
+func (h *History) Indexes() ([]*Index, error) {
+	names, err := h.storage.List(".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+
+	var indexes []*Index
+
+	for _, name := range names {
+		// Top level names starting with `_` are reserved, e.g. the asset store, they never contain an index.
+		if path.Base(name) != IndexFilename || strings.HasPrefix(name, "_") {
+			continue
+		}
+
+		body, err := h.storage.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read index: %w", err)
+		}
+
+		var index *Index
+
+		if err := json.Unmarshal(body, &index); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal index: %s: %w", name, err)
+		}
+
+		sort.SliceStable(index.Snapshots, func(i, j int) bool {
+			return index.Snapshots[i].FetchedAt.Before(index.Snapshots[j].FetchedAt)
+		})
+
+		indexes = append(indexes, index)
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i].URL < indexes[j].URL
+	})
+
+	return indexes, nil
+}
+
+// Find returns the snapshot of the URL matching `at`, which is either `latest`, `previous`, a snapshot ID,
+// or an RFC3339 time selecting the latest snapshot fetched at or before that time.
+func (h *History) Find(url, at string) (Snapshot, error) {
+	index, err := h.Index(url)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	if len(index.Snapshots) == 0 {
+		return Snapshot{}, fmt.Errorf("%w: %s has no snapshot", errSnapshotNotFound, url)
+	}
+
+	if at == "" || at == Latest {
+		return index.Snapshots[len(index.Snapshots)-1], nil
+	}
+
+	if at == Previous {
+		if len(index.Snapshots) < 2 {
+			return Snapshot{}, fmt.Errorf("%w: %s has no previous snapshot", errSnapshotNotFound, url)
+		}
+
+		return index.Snapshots[len(index.Snapshots)-2], nil
+	}
+
+	if i := index.find(at); i >= 0 {
+		return index.Snapshots[i], nil
+	}
+
+	atTime, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("%w: %s: %s isn't latest, previous, an ID or an RFC3339 time", errSnapshotNotFound, url, at)
+	}
+
+	for i := len(index.Snapshots) - 1; i >= 0; i-- {
+		if !index.Snapshots[i].FetchedAt.After(atTime) {
+			return index.Snapshots[i], nil
+		}
+	}
+
+	return Snapshot{}, fmt.Errorf("%w: %s: no snapshot at or before %s", errSnapshotNotFound, url, at)
+}
+
+// Prune removes the snapshots of the URL not matching the retention policy and returns them.
+// The latest snapshot is never removed.
+func (h *History) Prune(url string, retention Retention, now time.Time) ([]Snapshot, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	index, err := h.Index(url)
+	if err != nil {
+		return nil, err
+	}
+
+	kept, removed := retention.split(index.Snapshots, now)
+
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	for _, snapshot := range removed {
+		if err := h.removeDir(snapshot.Dir); err != nil {
+			return nil, err
+		}
+	}
+
+	index.Snapshots = kept
+
+	if err := h.saveIndex(index); err != nil {
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+// Expired returns the snapshots of the index not matching the retention policy, which Prune removes.
+// The latest snapshot is never expired.
+func (r Retention) Expired(index *Index, now time.Time) []Snapshot {
+	_, removed := r.split(index.Snapshots, now)
+
+	return removed
+}
+
+// split splits the snapshots sorted from the oldest to the latest into the kept and the expired ones.
+func (r Retention) split(snapshots []Snapshot, now time.Time) ([]Snapshot, []Snapshot) {
+	var kept, removed []Snapshot
+
+	for i, snapshot := range snapshots {
+		newer := len(snapshots) - 1 - i
+
+		expired := r.Keep > 0 && newer >= r.Keep
+		if r.KeepFor > 0 && now.Sub(snapshot.FetchedAt) > r.KeepFor {
+			expired = true
+		}
+
+		if expired && newer > 0 {
+			removed = append(removed, snapshot)
+		} else {
+			kept = append(kept, snapshot)
+		}
+	}
+
+	return kept, removed
+}
+
+// Discard removes the files of a snapshot which isn't committed, e.g. when saving it fails or is canceled.
+func (h *History) Discard(snapshot Snapshot) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// The ID stays reserved if the files can't be removed, so no other snapshot reuses them.
+	if err := h.removeDir(snapshot.Dir); err != nil {
+		return err
+	}
+
+	delete(h.pending, snapshot.Dir)
+
+	return nil
+}
+
+// Files lists the files of the snapshot.
+func (h *History) Files(snapshot Snapshot) ([]string, error) {
+	names, err := h.storage.List(snapshot.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot: %w", err)
+	}
+
+	return names, nil
+}
+
+// saveIndex writes the index of the URL.
+func (h *History) saveIndex(index *Index) error {
+	indexFile, err := indexPath(index.URL)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.MarshalIndent(index, "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal indent index: %w", err)
+	}
+
+	if err := h.storage.WriteFile(indexFile, body); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+
+	return nil
+}
+
+// removeDir removes every file of the directory.
+func (h *History) removeDir(dir string) error {
+	names, err := h.storage.List(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list snapshot: %w", err)
+	}
+
+	for _, name := range names {
+		if err := h.storage.Remove(name); err != nil {
+			return fmt.Errorf("failed to remove snapshot: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// find returns the position of the snapshot with the given ID, or -1.
+func (i *Index) find(id string) int {
+	for j, snapshot := range i.Snapshots {
+		if snapshot.ID == id {
+			return j
+		}
+	}
+
+	return -1
+}
+
+// indexPath returns the path of the index of the URL.
+func indexPath(url string) (string, error) {
+	urlDir, err := utils.URLToPath(url)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(urlDir, IndexFilename), nil
+}
+
+// ParseAge parses the age of the retention policy, it accepts time.ParseDuration units and `d` for days, e.g. `30d`.
+func ParseAge(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		count, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || count < 0 {
+			return 0, fmt.Errorf("%w: %s", errInvalidAge, value)
+		}
+
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("%w: %s", errInvalidAge, value)
+	}
+
+	return age, nil
+}
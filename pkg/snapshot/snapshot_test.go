@@ -0,0 +1,259 @@
+package snapshot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moemoe89/fetch/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testURL = "https://example.com/docs"
+
+// commitTestSnapshots commits a snapshot with a page file for every fetch time.
+func commitTestSnapshots(t *testing.T, h *History, s storage.Storage, times ...time.Time) []Snapshot {
+	t.Helper()
+
+	snapshots := make([]Snapshot, 0, len(times))
+
+	for _, fetchedAt := range times {
+		snapshot, _, err := h.Begin(testURL, fetchedAt)
+		require.NoError(t, err)
+
+		require.NoError(t, s.WriteFile(snapshot.Dir+"/_page.html", []byte(snapshot.ID)))
+		require.NoError(t, h.Commit(testURL, snapshot))
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots
+}
+
+func TestHistory(t *testing.T) {
+	s := storage.NewMemory()
+	h := New(s)
+
+	first := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+
+	snapshots := commitTestSnapshots(t, h, s, first, first, first.Add(24*time.Hour))
+
+	assert.Equal(t, "https/example.com/docs/_snapshots/20261001T100000Z", snapshots[0].Dir)
+	assert.Equal(t, "20261001T100000Z-2", snapshots[1].ID)
+
+	_, previous, err := h.Begin(testURL, first.Add(48*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, snapshots[2], *previous)
+
+	type test struct {
+		at      string
+		want    Snapshot
+		wantErr error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully find latest snapshot": func(t *testing.T) test {
+			t.Helper()
+
+			return test{at: Latest, want: snapshots[2]}
+		},
+		"Successfully find previous snapshot": func(t *testing.T) test {
+			t.Helper()
+
+			return test{at: Previous, want: snapshots[1]}
+		},
+		"Successfully find snapshot by ID": func(t *testing.T) test {
+			t.Helper()
+
+			return test{at: snapshots[1].ID, want: snapshots[1]}
+		},
+		"Successfully find snapshot by time": func(t *testing.T) test {
+			t.Helper()
+
+			return test{at: "2026-10-01T23:00:00Z", want: snapshots[1]}
+		},
+		"Failed find snapshot before the first one": func(t *testing.T) test {
+			t.Helper()
+
+			return test{at: "2026-09-01T00:00:00Z", wantErr: errSnapshotNotFound}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			got, err := h.Find(testURL, tt.at)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.ID, got.ID)
+		})
+	}
+}
+
+func TestHistoryIndexes(t *testing.T) {
+	s := storage.NewMemory()
+	h := New(s)
+
+	fetchedAt := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+
+	commitTestSnapshots(t, h, s, fetchedAt, fetchedAt.Add(time.Hour))
+
+	for _, url := range []string{"https://example.com", "https://example.com/docs/a"} {
+		snapshot, _, err := h.Begin(url, fetchedAt)
+		require.NoError(t, err)
+		require.NoError(t, h.Commit(url, snapshot))
+	}
+
+	// Files of the asset store are never read as an index.
+	require.NoError(t, s.WriteFile("_store/sha256/ab/"+IndexFilename, []byte("not json")))
+
+	indexes, err := h.Indexes()
+	require.NoError(t, err)
+
+	require.Len(t, indexes, 3)
+	assert.Equal(t, "https://example.com", indexes[0].URL)
+	assert.Equal(t, testURL, indexes[1].URL)
+	assert.Len(t, indexes[1].Snapshots, 2)
+	assert.Equal(t, "https://example.com/docs/a", indexes[2].URL)
+}
+
+func TestHistoryDiscard(t *testing.T) {
+	s := storage.NewMemory()
+	h := New(s)
+
+	fetchedAt := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+
+	committed := commitTestSnapshots(t, h, s, fetchedAt)
+
+	snapshot, _, err := h.Begin(testURL, fetchedAt)
+	require.NoError(t, err)
+	require.NoError(t, s.WriteFile(snapshot.Dir+"/_page.html", []byte("partial")))
+
+	require.NoError(t, h.Discard(snapshot))
+
+	files, err := h.Files(snapshot)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	files, err = h.Files(committed[0])
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestHistoryBeginReservesID(t *testing.T) {
+	h := New(storage.NewMemory())
+
+	fetchedAt := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+
+	first, _, err := h.Begin(testURL, fetchedAt)
+	require.NoError(t, err)
+
+	second, _, err := h.Begin(testURL, fetchedAt)
+	require.NoError(t, err)
+	assert.Equal(t, "20261001T100000Z", first.ID)
+	assert.Equal(t, "20261001T100000Z-2", second.ID)
+
+	// A discarded ID is free again, a committed ID is in the index.
+	require.NoError(t, h.Discard(first))
+	require.NoError(t, h.Commit(testURL, second))
+
+	third, _, err := h.Begin(testURL, fetchedAt)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, third.ID)
+
+	fourth, _, err := h.Begin(testURL, fetchedAt)
+	require.NoError(t, err)
+	assert.Equal(t, "20261001T100000Z-3", fourth.ID)
+}
+
+func TestHistoryPrune(t *testing.T) {
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+	type test struct {
+		retention Retention
+		times     []time.Time
+		wantKept  int
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Keep the latest snapshots": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				retention: Retention{Keep: 2},
+				times:     []time.Time{now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-time.Hour)},
+				wantKept:  2,
+			}
+		},
+		"Keep the recent snapshots": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				retention: Retention{KeepFor: 30 * 24 * time.Hour},
+				times:     []time.Time{now.Add(-40 * 24 * time.Hour), now.Add(-20 * 24 * time.Hour), now.Add(-time.Hour)},
+				wantKept:  2,
+			}
+		},
+		"Always keep the latest snapshot": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				retention: Retention{KeepFor: time.Hour},
+				times:     []time.Time{now.Add(-40 * 24 * time.Hour), now.Add(-20 * 24 * time.Hour)},
+				wantKept:  1,
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			s := storage.NewMemory()
+			h := New(s)
+
+			snapshots := commitTestSnapshots(t, h, s, tt.times...)
+
+			index, err := h.Index(testURL)
+			require.NoError(t, err)
+
+			expired := tt.retention.Expired(index, now)
+
+			removed, err := h.Prune(testURL, tt.retention, now)
+			require.NoError(t, err)
+			assert.Equal(t, expired, removed)
+
+			index, err = h.Index(testURL)
+			require.NoError(t, err)
+
+			assert.Len(t, index.Snapshots, tt.wantKept)
+			assert.Len(t, removed, len(tt.times)-tt.wantKept)
+			assert.Equal(t, snapshots[len(snapshots)-1].ID, index.Snapshots[len(index.Snapshots)-1].ID)
+
+			for _, snapshot := range removed {
+				files, err := h.Files(snapshot)
+				require.NoError(t, err)
+				assert.Empty(t, files)
+			}
+		})
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	age, err := ParseAge("30d")
+	require.NoError(t, err)
+	assert.Equal(t, 30*24*time.Hour, age)
+
+	age, err = ParseAge("12h")
+	require.NoError(t, err)
+	assert.Equal(t, 12*time.Hour, age)
+
+	_, err = ParseAge("-1d")
+	assert.ErrorIs(t, err, errInvalidAge)
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tempPrefix is the filename prefix of the temporary files, they're hidden from List.
+const tempPrefix = ".tmp-"
+
+// fileMode is the mode of the saved files, the temporary files are created readable by their owner only.
+const fileMode = 0644
+
+// Compile time interface implementation check.
+var _ Storage = (*Local)(nil)
+
+// Local is a Storage on the local filesystem under a root directory.
+type Local struct {
+	root string
+}
+
+// NewLocal returns a Storage that keeps the files under the `root` directory.
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+// Root returns the root directory of the storage.
+func (l *Local) Root() string {
+	return l.root
+}
+
+// Path returns the path of the named file on the local filesystem.
+func (l *Local) Path(name string) (string, error) {
+	cleaned, err := CleanName(name)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(l.root, filepath.FromSlash(cleaned)), nil
+}
+
+// WriteFile writes the data to a temporary file and renames it to the named file.
+func (l *Local) WriteFile(name string, data []byte) error {
+	writer, err := l.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		_ = writer.Close()
+
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return writer.Close()
+}
+
+// ReadFile reads the named file.
+func (l *Local) ReadFile(name string) ([]byte, error) {
+	filePath, err := l.Path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return data, nil
+}
+
+// Create creates a temporary file next to the named file, it's renamed to the named file when closed.
+func (l *Local) Create(name string) (io.WriteCloser, error) {
+	filePath, err := l.Path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create dir: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), tempPrefix+filepath.Base(filePath)+"-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+
+	return &localWriter{File: tmpFile, target: filePath}, nil
+}
+
+// Open opens the named file.
+func (l *Local) Open(name string) (io.ReadCloser, error) {
+	filePath, err := l.Path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return file, nil
+}
+
+// Stat returns the size of the named file.
+func (l *Local) Stat(name string) (int64, error) {
+	filePath, err := l.Path(name)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	if info.IsDir() {
+		return 0, fmt.Errorf("failed to stat file: %s: %w", name, ErrNotExist)
+	}
+
+	return info.Size(), nil
+}
+
+// List walks the `dir` directory and returns the names of the files, temporary files are skipped.
+func (l *Local) List(dir string) ([]string, error) {
+	dirPath, err := l.Path(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	err = filepath.WalkDir(dirPath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// The missing dir has no files, the same as an empty prefix in other storages.
+			if filePath == dirPath && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+
+			return err
+		}
+
+		if entry.IsDir() || isTempFile(entry.Name()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.root, filePath)
+		if err != nil {
+			return err
+		}
+
+		names = append(names, filepath.ToSlash(rel))
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dir: %w", err)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Remove removes the named file.
+func (l *Local) Remove(name string) error {
+	filePath, err := l.Path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil {
+		return fmt.Errorf("failed to remove file: %w", err)
+	}
+
+	// Removes the parent directories left empty, like the other storages without directories.
+	root := filepath.Clean(l.root)
+
+	for dir := filepath.Dir(filePath); dir != root && dir != "."; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// localWriter writes to a temporary file and renames it to the target when closed.
+type localWriter struct {
+	*os.File
+	target string
+}
+
+// Close closes the temporary file and renames it to the target.
+func (w *localWriter) Close() error {
+	defer func() { _ = os.Remove(w.File.Name()) }()
+
+	if err := w.File.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	if err := os.Chmod(w.File.Name(), fileMode); err != nil {
+		return fmt.Errorf("failed to chmod file: %w", err)
+	}
+
+	if err := os.Rename(w.File.Name(), w.target); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+
+	return nil
+}
+
+// isTempFile reports whether the file is a temporary file created by Create.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, tempPrefix)
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Compile time interface implementation check.
+var _ Storage = (*Memory)(nil)
+
+// Memory is a Storage keeping the files in memory, it's useful for tests.
+type Memory struct {
+	mutex sync.RWMutex
+	files map[string][]byte
+}
+
+// NewMemory returns an empty in-memory Storage.
+func NewMemory() *Memory {
+	return &Memory{files: make(map[string][]byte)}
+}
+
+// WriteFile stores a copy of the data under the name.
+func (m *Memory) WriteFile(name string, data []byte) error {
+	cleaned, err := CleanName(name)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.files[cleaned] = append([]byte(nil), data...)
+
+	return nil
+}
+
+// ReadFile returns a copy of the named file.
+func (m *Memory) ReadFile(name string) ([]byte, error) {
+	cleaned, err := CleanName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	data, ok := m.files[cleaned]
+	if !ok {
+		return nil, fmt.Errorf("failed to read file: %s: %w", name, ErrNotExist)
+	}
+
+	return append([]byte(nil), data...), nil
+}
+
+// Create returns a writer storing the content when closed.
+func (m *Memory) Create(name string) (io.WriteCloser, error) {
+	if _, err := CleanName(name); err != nil {
+		return nil, err
+	}
+
+	return &bufferWriter{commit: func(data []byte) error {
+		return m.WriteFile(name, data)
+	}}, nil
+}
+
+// Open returns a reader of the named file.
+func (m *Memory) Open(name string) (io.ReadCloser, error) {
+	data, err := m.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Stat returns the size of the named file.
+func (m *Memory) Stat(name string) (int64, error) {
+	cleaned, err := CleanName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	data, ok := m.files[cleaned]
+	if !ok {
+		return 0, fmt.Errorf("failed to stat file: %s: %w", name, ErrNotExist)
+	}
+
+	return int64(len(data)), nil
+}
+
+// List returns the names of the files under the `dir` directory.
+func (m *Memory) List(dir string) ([]string, error) {
+	prefix, err := cleanDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var names []string
+
+	for name := range m.files {
+		if strings.HasPrefix(name, prefix) || name+"/" == prefix {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Remove removes the named file.
+func (m *Memory) Remove(name string) error {
+	cleaned, err := CleanName(name)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.files[cleaned]; !ok {
+		return fmt.Errorf("failed to remove file: %s: %w", name, ErrNotExist)
+	}
+
+	delete(m.files, cleaned)
+
+	return nil
+}
@@ -0,0 +1,460 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Compile time interface implementation check.
+var _ Storage = (*S3)(nil)
+
+var (
+	// errInvalidS3Config represents an error message when the S3 config misses the endpoint or bucket.
+	errInvalidS3Config = errors.New("invalid s3 config")
+	// errS3Response represents an error message when the S3 server responds with an unexpected status code.
+	errS3Response = errors.New("unexpected s3 response")
+)
+
+// s3 signature constants.
+const (
+	s3Algorithm   = "AWS4-HMAC-SHA256"
+	s3Service     = "s3"
+	s3DateFormat  = "20060102"
+	s3TimeFormat  = "20060102T150405Z"
+	s3EmptyHash   = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	defaultRegion = "us-east-1"
+	// defaultS3Timeout is the maximum duration of a request of the default HTTP client, a stalled server doesn't block forever.
+	defaultS3Timeout = 2 * time.Minute
+)
+
+// HTTPClient is an interface that defines the methods of an HTTP client.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// S3Config configures the S3 storage.
+type S3Config struct {
+	// Endpoint is the base URL of the S3-compatible server, e.g. `https://s3.amazonaws.com` or `http://localhost:9000`.
+	Endpoint string
+	// Region is the region used to sign the requests, defaults to `us-east-1`.
+	Region string
+	// Bucket is the name of the bucket, objects are addressed with the path style `<endpoint>/<bucket>/<key>`.
+	Bucket string
+	// Prefix is prepended to every object key, e.g. `archives/`.
+	Prefix string
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials to sign the requests with AWS Signature Version 4.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// HTTPClient is used to send the requests, defaults to an HTTP client with the Timeout.
+	HTTPClient HTTPClient
+	// Timeout is the maximum duration of a request of the default HTTP client, including its body, defaults to 2 minutes.
+	Timeout time.Duration
+}
+
+// S3 is a Storage keeping the files as objects of an S3-compatible bucket.
+type S3 struct {
+	config S3Config
+	now    func() time.Time
+}
+
+// listBucketResult is the response of the ListObjectsV2 request.
+type listBucketResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// NewS3 returns a Storage backed by an S3-compatible bucket.
+func NewS3(config S3Config) (*S3, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errInvalidS3Config
+	}
+
+	if config.Region == "" {
+		config.Region = defaultRegion
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = defaultS3Timeout
+	}
+
+	// The requests aren't bound to the context of a run, the partial files of a canceled run are still removed,
+	// so they're bounded by the timeout instead.
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: config.Timeout}
+	}
+
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	config.Prefix = strings.Trim(config.Prefix, "/")
+
+	return &S3{config: config, now: time.Now}, nil
+}
+
+// WriteFile uploads the data as the named object, S3 replaces objects atomically.
+func (s *S3) WriteFile(name string, data []byte) error {
+	key, err := s.key(name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodPut, key, nil, data)
+	if err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+
+	_ = resp.Body.Close()
+
+	return nil
+}
+
+// ReadFile downloads the named object.
+func (s *S3) ReadFile(name string) ([]byte, error) {
+	reader, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+
+	return data, nil
+}
+
+// Create returns a writer uploading the content when closed.
+func (s *S3) Create(name string) (io.WriteCloser, error) {
+	if _, err := s.key(name); err != nil {
+		return nil, err
+	}
+
+	return &bufferWriter{commit: func(data []byte) error {
+		return s.WriteFile(name, data)
+	}}, nil
+}
+
+// Open returns the body of the named object.
+func (s *S3) Open(name string) (io.ReadCloser, error) {
+	key, err := s.key(name)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	return resp.Body, nil
+}
+
+// Stat returns the size of the named object.
+func (s *S3) Stat(name string) (int64, error) {
+	key, err := s.key(name)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := s.do(http.MethodHead, key, nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to head object: %w", err)
+	}
+
+	_ = resp.Body.Close()
+
+	return resp.ContentLength, nil
+}
+
+// List returns the names of the objects under the `dir` prefix, following the continuation tokens.
+func (s *S3) List(dir string) ([]string, error) {
+	prefix, err := cleanDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.config.Prefix != "" {
+		prefix = s.config.Prefix + "/" + prefix
+	}
+
+	var names []string
+
+	token := ""
+
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		result, err := s.list(query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, content := range result.Contents {
+			names = append(names, s.name(content.Key))
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+
+		token = result.NextContinuationToken
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Remove deletes the named object.
+func (s *S3) Remove(name string) error {
+	key, err := s.key(name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	_ = resp.Body.Close()
+
+	return nil
+}
+
+// list sends a ListObjectsV2 request.
+func (s *S3) list(query url.Values) (*listBucketResult, error) {
+	resp, err := s.do(http.MethodGet, "", query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	var result listBucketResult
+
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode list objects: %w", err)
+	}
+
+	return &result, nil
+}
+
+// key converts the storage name to the object key.
+func (s *S3) key(name string) (string, error) {
+	cleaned, err := CleanName(name)
+	if err != nil {
+		return "", err
+	}
+
+	if s.config.Prefix == "" {
+		return cleaned, nil
+	}
+
+	return s.config.Prefix + "/" + cleaned, nil
+}
+
+// name converts the object key back to the storage name.
+func (s *S3) name(key string) string {
+	if s.config.Prefix == "" {
+		return key
+	}
+
+	return strings.TrimPrefix(key, s.config.Prefix+"/")
+}
+
+// do sends the signed request for the object key, or for the bucket if the key is empty.
+// Responses other than 2xx are returned as errors, 404 wraps ErrNotExist.
+func (s *S3) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	rawURL := s.config.Endpoint + "/" + uriEncode(s.config.Bucket, false)
+	if key != "" {
+		rawURL += "/" + uriEncode(key, false)
+	}
+
+	if len(query) > 0 {
+		rawURL += "?" + canonicalQuery(query)
+	}
+
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	payloadHash := s3EmptyHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+
+	s.sign(req, payloadHash)
+
+	resp, err := s.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to do HTTP request: %w", err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s %s: %w", method, key, ErrNotExist)
+	}
+
+	return nil, fmt.Errorf("%w: %s %s: %s: %s", errS3Response, method, key, resp.Status, bytes.TrimSpace(message))
+}
+
+// sign signs the request with AWS Signature Version 4.
+// The host, range and x-amz-* headers are signed.
+func (s *S3) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+
+	req.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	if s.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.config.SessionToken)
+	}
+
+	if s.config.AccessKeyID == "" {
+		return
+	}
+
+	req.Header.Set("Authorization", s.authorization(req, payloadHash, now))
+}
+
+// authorization builds the Authorization header value of the request.
+func (s *S3) authorization(req *http.Request, payloadHash string, now time.Time) string {
+	headers := map[string]string{"host": req.URL.Host}
+
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := now.Format(s3DateFormat) + "/" + s.config.Region + "/" + s3Service + "/aws4_request"
+
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		now.Format(s3TimeFormat),
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), now.Format(s3DateFormat))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	return fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.config.AccessKeyID, scope, signedHeaders, signature)
+}
+
+// canonicalQuery encodes the query sorted by key as required by the signature.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var pairs []string
+
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode encodes every character except the unreserved ones, the slash is kept unless `encodeSlash` is true.
+func uriEncode(value string, encodeSlash bool) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+
+		switch {
+		case 'A' <= char && char <= 'Z', 'a' <= char && char <= 'z', '0' <= char && char <= '9',
+			char == '-', char == '_', char == '.', char == '~':
+			builder.WriteByte(char)
+		case char == '/' && !encodeSlash:
+			builder.WriteByte(char)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", char)
+		}
+	}
+
+	return builder.String()
+}
+
+// hashHex returns the SHA-256 hex digest of the data.
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data with the key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
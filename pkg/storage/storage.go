@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// errInvalidName represents an error message when the file name is absolute, not normalized or escapes the storage root.
+var errInvalidName = errors.New("invalid storage name")
+
+// ErrNotExist is returned when the file doesn't exist in the storage, it's the same error as fs.ErrNotExist.
+var ErrNotExist = fs.ErrNotExist
+
+// Storage is an interface that defines the methods to read and write the fetched pages, assets and archives.
+// Every name is a slash separated path relative to the root of the storage, e.g. `example.com/style.css`.
+type Storage interface {
+	// WriteFile writes the data to the named file, creating the parent directories as needed.
+	// The file is replaced atomically, readers never see a partially written file.
+	WriteFile(name string, data []byte) error
+	// ReadFile reads the named file and returns its content.
+	ReadFile(name string) ([]byte, error)
+	// Create returns a writer to the named file, the content is committed when the writer is closed.
+	Create(name string) (io.WriteCloser, error)
+	// Open returns a reader of the named file.
+	Open(name string) (io.ReadCloser, error)
+	// Stat returns the size of the named file, or an error wrapping ErrNotExist if it doesn't exist.
+	Stat(name string) (int64, error)
+	// List returns the names of all files under the `dir` directory recursively, sorted by name.
+	List(dir string) ([]string, error)
+	// Remove removes the named file.
+	Remove(name string) error
+}
+
+// CleanName validates and normalizes the storage name.
+// Absolute names and names escaping the storage root are rejected.
+func CleanName(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+
+	if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return "", fmt.Errorf("%w: %s: absolute path", errInvalidName, name)
+	}
+
+	cleaned := path.Clean(name)
+
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: %s: escapes storage root", errInvalidName, name)
+	}
+
+	return cleaned, nil
+}
+
+// cleanDir normalizes the directory name used as prefix, the root is returned as an empty string.
+func cleanDir(dir string) (string, error) {
+	cleaned, err := CleanName(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if cleaned == "." {
+		return "", nil
+	}
+
+	return cleaned + "/", nil
+}
+
+// bufferWriter buffers the written content and commits it with the given function when closed.
+type bufferWriter struct {
+	buf    []byte
+	commit func(data []byte) error
+	closed bool
+}
+
+// Write appends the content to the buffer.
+func (w *bufferWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fs.ErrClosed
+	}
+
+	w.buf = append(w.buf, p...)
+
+	return len(p), nil
+}
+
+// Close commits the buffered content.
+func (w *bufferWriter) Close() error {
+	if w.closed {
+		return fs.ErrClosed
+	}
+
+	w.closed = true
+
+	return w.commit(w.buf)
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	tests := map[string]func(t *testing.T) Storage{
+		"Local": func(t *testing.T) Storage {
+			t.Helper()
+
+			return NewLocal(t.TempDir())
+		},
+		"Memory": func(t *testing.T) Storage {
+			t.Helper()
+
+			return NewMemory()
+		},
+		"S3": func(t *testing.T) Storage {
+			t.Helper()
+
+			return newTestS3(t)
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := fn(t)
+
+			require.NoError(t, s.WriteFile("example.com/index.html", []byte("<html></html>")))
+			require.NoError(t, s.WriteFile("example.com/assets/style.css", []byte("body{}")))
+			require.NoError(t, s.WriteFile("other.com/index.html", []byte("<html>other</html>")))
+
+			writer, err := s.Create("example.com.zip")
+			require.NoError(t, err)
+
+			_, err = writer.Write([]byte("zip"))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			data, err := s.ReadFile("example.com/index.html")
+			require.NoError(t, err)
+			assert.Equal(t, []byte("<html></html>"), data)
+
+			reader, err := s.Open("example.com.zip")
+			require.NoError(t, err)
+
+			data, err = io.ReadAll(reader)
+			require.NoError(t, err)
+			require.NoError(t, reader.Close())
+			assert.Equal(t, []byte("zip"), data)
+
+			size, err := s.Stat("example.com/assets/style.css")
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), size)
+
+			names, err := s.List("example.com")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"example.com/assets/style.css", "example.com/index.html"}, names)
+
+			names, err = s.List("missing")
+			require.NoError(t, err)
+			assert.Empty(t, names)
+
+			require.NoError(t, s.Remove("example.com/index.html"))
+
+			_, err = s.ReadFile("example.com/index.html")
+			assert.True(t, errors.Is(err, ErrNotExist))
+
+			_, err = s.Stat("example.com/index.html")
+			assert.True(t, errors.Is(err, ErrNotExist))
+
+			assert.ErrorIs(t, s.WriteFile("../escape.html", nil), errInvalidName)
+			assert.ErrorIs(t, s.WriteFile("/etc/passwd", nil), errInvalidName)
+		})
+	}
+}
+
+func TestLocalFileMode(t *testing.T) {
+	s := NewLocal(t.TempDir())
+
+	require.NoError(t, s.WriteFile("example.com/index.html", []byte("<html></html>")))
+
+	filePath, err := s.Path("example.com/index.html")
+	require.NoError(t, err)
+
+	info, err := os.Stat(filePath)
+	require.NoError(t, err)
+
+	// The saved files are readable by everyone, like os.WriteFile with 0644.
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
@@ -0,0 +1,268 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/moemoe89/fetch/pkg/fetcher"
+	"github.com/moemoe89/fetch/pkg/storage"
+)
+
+// Algorithm is the hash algorithm used to address the blobs.
+const Algorithm = "sha256"
+
+// ManifestFilename is the filename of the per-page manifest saved in the page directory.
+const ManifestFilename = "_manifest.json"
+
+// manifestsDir is the directory of the store registering the saved manifests, every manifest has an empty file
+// named after its path, so the manifests are listed without listing the whole storage.
+const manifestsDir = "manifests"
+
+// errInvalidDigest represents an error message when the digest isn't a SHA-256 hex digest.
+var errInvalidDigest = errors.New("invalid digest")
+
+// safeExt matches the extensions kept on the blob filename, so the assets are served with the right content type.
+var safeExt = regexp.MustCompile(`^\.[A-Za-z0-9]{1,8}$`)
+
+// digestPattern matches a SHA-256 hex digest.
+var digestPattern = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// Store is a content-addressed blob store, every blob is keyed by the SHA-256 digest of its content
+// so identical assets fetched from different pages are stored once.
+//
+// The blobs are stored in the storage as `<dir>/sha256/<first 2 hex>/<digest><ext>`.
+//
+// A storage must have a single writer: the blobs removed by Sweep are only safe from the pages saved by the same process.
+type Store struct {
+	storage storage.Storage
+	dir     string
+}
+
+// Blob is a content stored in the Store.
+type Blob struct {
+	Digest string
+	Path   string
+	Size   int64
+}
+
+// Manifest lists the assets of a page and where they are stored.
+type Manifest struct {
+	Site   string          `json:"site"`
+	Assets []ManifestAsset `json:"assets"`
+}
+
+// ManifestAsset is an asset of a page stored in the Store.
+type ManifestAsset struct {
+	URL    string `json:"url"`
+	Digest string `json:"digest"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+}
+
+// New returns a Store that keeps the blobs under `dir` of the storage.
+func New(s storage.Storage, dir string) *Store {
+	return &Store{storage: s, dir: dir}
+}
+
+// Dir returns the root directory of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Path returns the path of the blob with the given digest and extension.
+func (s *Store) Path(digest, ext string) (string, error) {
+	if !digestPattern.MatchString(digest) {
+		return "", fmt.Errorf("%w: %s", errInvalidDigest, digest)
+	}
+
+	if !safeExt.MatchString(ext) {
+		ext = ""
+	}
+
+	return path.Join(s.dir, Algorithm, digest[:2], digest+ext), nil
+}
+
+// Put stores the body unless a blob with the same digest and extension already exists.
+// The storage replaces files atomically, so concurrent puts of the same content are safe.
+func (s *Store) Put(body []byte, ext string) (Blob, error) {
+	digest := fetcher.Checksum(body)
+
+	blobPath, err := s.Path(digest, ext)
+	if err != nil {
+		return Blob{}, err
+	}
+
+	blob := Blob{Digest: digest, Path: blobPath, Size: int64(len(body))}
+
+	// The content is already stored.
+	if _, err := s.storage.Stat(blobPath); err == nil {
+		return blob, nil
+	}
+
+	if err := s.storage.WriteFile(blobPath, body); err != nil {
+		return Blob{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	return blob, nil
+}
+
+// Get reads the blob with the given digest and extension.
+func (s *Store) Get(digest, ext string) ([]byte, error) {
+	blobPath, err := s.Path(digest, ext)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.storage.ReadFile(blobPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+
+	return body, nil
+}
+
+// Stat returns the blob with the given digest and extension, or an error wrapping storage.ErrNotExist
+// if it isn't stored, e.g. to reuse an asset downloaded by an interrupted job.
+func (s *Store) Stat(digest, ext string) (Blob, error) {
+	blobPath, err := s.Path(digest, ext)
+	if err != nil {
+		return Blob{}, err
+	}
+
+	size, err := s.storage.Stat(blobPath)
+	if err != nil {
+		return Blob{}, fmt.Errorf("failed to stat blob: %w", err)
+	}
+
+	return Blob{Digest: digest, Path: blobPath, Size: size}, nil
+}
+
+// Add adds the asset stored as blob to the manifest.
+func (m *Manifest) Add(assetURL string, blob Blob) {
+	m.Assets = append(m.Assets, ManifestAsset{
+		URL:    assetURL,
+		Digest: blob.Digest,
+		Path:   blob.Path,
+		Size:   blob.Size,
+	})
+}
+
+// SaveManifest sorts the manifest assets by URL and writes the manifest to JSON file in the storage.
+// The manifest is registered in the store first, so Sweep sees it even if writing it is interrupted.
+func (s *Store) SaveManifest(manifest *Manifest, filePath string) error {
+	sort.Slice(manifest.Assets, func(i, j int) bool {
+		return manifest.Assets[i].URL < manifest.Assets[j].URL
+	})
+
+	body, err := json.MarshalIndent(manifest, "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal indent manifest: %w", err)
+	}
+
+	if err := s.storage.WriteFile(path.Join(s.dir, manifestsDir, filePath), nil); err != nil {
+		return fmt.Errorf("failed to register manifest: %w", err)
+	}
+
+	if err := s.storage.WriteFile(filePath, body); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// LoadManifest reads the manifest JSON file from the storage.
+func (s *Store) LoadManifest(filePath string) (*Manifest, error) {
+	body, err := s.storage.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest *Manifest
+
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// Sweep removes the candidate blobs, e.g. the assets of pruned snapshots, which no manifest saved by the store references,
+// and returns their paths. The registered manifests which were removed, e.g. with their snapshot, are unregistered.
+// The manifests being written meanwhile aren't seen, so the caller must not save pages concurrently,
+// neither in this process nor in another one.
+func (s *Store) Sweep(candidates []string) ([]string, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	registry := path.Join(s.dir, manifestsDir)
+
+	names, err := s.storage.List(registry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list manifests: %w", err)
+	}
+
+	referenced := make(map[string]bool)
+
+	for _, name := range names {
+		manifest, err := s.LoadManifest(strings.TrimPrefix(name, registry+"/"))
+		if errors.Is(err, storage.ErrNotExist) {
+			if err := s.storage.Remove(name); err != nil && !errors.Is(err, storage.ErrNotExist) {
+				return nil, fmt.Errorf("failed to unregister manifest: %w", err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, name)
+		}
+
+		for _, asset := range manifest.Assets {
+			referenced[asset.Path] = true
+		}
+	}
+
+	var removed []string
+
+	for _, candidate := range candidates {
+		// Only the blobs of the store are removed, whatever the manifests list.
+		if referenced[candidate] || !strings.HasPrefix(candidate, s.dir+"/") {
+			continue
+		}
+
+		if _, err := s.storage.Stat(candidate); errors.Is(err, storage.ErrNotExist) {
+			continue
+		}
+
+		if err := s.storage.Remove(candidate); err != nil {
+			return removed, fmt.Errorf("failed to remove blob: %w", err)
+		}
+
+		removed = append(removed, candidate)
+	}
+
+	return removed, nil
+}
+
+// Ext returns the extension of the asset URL path, e.g. `.css` for `https://example.com/style.css?v=1`.
+func Ext(assetURL string) string {
+	assetPath := assetURL
+
+	if parsed, err := url.Parse(assetURL); err == nil {
+		assetPath = parsed.Path
+	}
+
+	ext := strings.ToLower(path.Ext(assetPath))
+	if !safeExt.MatchString(ext) {
+		return ""
+	}
+
+	return ext
+}
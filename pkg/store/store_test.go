@@ -0,0 +1,173 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/moemoe89/fetch/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorePut(t *testing.T) {
+	memory := storage.NewMemory()
+
+	s := New(memory, "_store")
+
+	first, err := s.Put([]byte("body{}"), ".css")
+	require.NoError(t, err)
+
+	second, err := s.Put([]byte("body{}"), ".css")
+	require.NoError(t, err)
+
+	other, err := s.Put([]byte("p{}"), ".css")
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first.Digest, other.Digest)
+	assert.Equal(t, "_store/sha256/"+first.Digest[:2]+"/"+first.Digest+".css", first.Path)
+
+	body, err := s.Get(first.Digest, ".css")
+	require.NoError(t, err)
+
+	assert.Equal(t, []byte("body{}"), body)
+
+	stat, err := s.Stat(first.Digest, ".css")
+	require.NoError(t, err)
+
+	assert.Equal(t, first, stat)
+
+	_, err = s.Stat(first.Digest, ".js")
+	assert.ErrorIs(t, err, storage.ErrNotExist)
+
+	// Only the two distinct blobs are stored.
+	names, err := memory.List("_store")
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{first.Path, other.Path}, names)
+}
+
+func TestStoreManifest(t *testing.T) {
+	s := New(storage.NewMemory(), "_store")
+
+	blob, err := s.Put([]byte("body{}"), ".css")
+	require.NoError(t, err)
+
+	manifest := &Manifest{Site: "https://example.com"}
+	manifest.Add("/style.css", blob)
+
+	require.NoError(t, s.SaveManifest(manifest, "example.com/manifest.json"))
+
+	got, err := s.LoadManifest("example.com/manifest.json")
+	require.NoError(t, err)
+
+	assert.Equal(t, manifest, got)
+}
+
+func TestStoreSweep(t *testing.T) {
+	memory := storage.NewMemory()
+
+	s := New(memory, "_store")
+
+	shared, err := s.Put([]byte("body{}"), ".css")
+	require.NoError(t, err)
+
+	pruned, err := s.Put([]byte("p{}"), ".css")
+	require.NoError(t, err)
+
+	kept := &Manifest{Site: "https://example.com"}
+	kept.Add("/style.css", shared)
+
+	require.NoError(t, s.SaveManifest(kept, "https/example.com/_snapshots/2/"+ManifestFilename))
+
+	removedManifest := &Manifest{Site: "https://example.com"}
+	removedManifest.Add("/print.css", pruned)
+
+	require.NoError(t, s.SaveManifest(removedManifest, "https/example.com/_snapshots/1/"+ManifestFilename))
+	require.NoError(t, memory.Remove("https/example.com/_snapshots/1/"+ManifestFilename))
+
+	// The manifest of the pruned snapshot is removed with its directory, only the shared blob is still referenced.
+	removed, err := s.Sweep([]string{shared.Path, pruned.Path, "https/example.com/_page.html"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{pruned.Path}, removed)
+
+	names, err := memory.List("_store")
+	require.NoError(t, err)
+
+	// The removed manifest is unregistered.
+	assert.Equal(t, []string{"_store/manifests/https/example.com/_snapshots/2/" + ManifestFilename, shared.Path}, names)
+}
+
+func TestStorePath(t *testing.T) {
+	type args struct {
+		digest string
+		ext    string
+	}
+
+	type test struct {
+		args    args
+		want    string
+		wantErr error
+	}
+
+	digest := "74d94aede163ac74eb42fe7cac4066626820fa15001ddf02c3b2d25df8e6c771"
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully build blob path": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{digest: digest, ext: ".css"},
+				want: "store/sha256/74/" + digest + ".css",
+			}
+		},
+		"Successfully build blob path without unsafe extension": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args: args{digest: digest, ext: "./../x"},
+				want: "store/sha256/74/" + digest,
+			}
+		},
+		"Failed build blob path with invalid digest": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				args:    args{digest: "../../etc/passwd"},
+				wantErr: errInvalidDigest,
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			got, err := New(storage.NewMemory(), "store").Path(tt.args.digest, tt.args.ext)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExt(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/style.css?v=1": ".css",
+		"/logo.PNG":                         ".png",
+		"https://example.com/":              "",
+		"https://example.com/script":        "",
+		"https://example.com/a.verylongext": "",
+	}
+
+	for assetURL, want := range tests {
+		t.Run(assetURL, func(t *testing.T) {
+			assert.Equal(t, want, Ext(assetURL))
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// maxSegmentLength is the maximum length of a path element built by URLToPath.
+	maxSegmentLength = 120
+	// maxPathLength is the maximum length of the whole path built by URLToPath.
+	maxPathLength = 240
+	// hashLength is the number of hex characters of the hash suffix of the shortened path elements.
+	hashLength = 16
+)
+
+// Markers of URLToPath, the escaped path elements never contain `~` so the markers are unambiguous.
+const (
+	emptySegment   = "_"
+	queryMarker    = "~q"
+	fragmentMarker = "~f"
+	hashMarker     = "~h"
+)
+
+// errInvalidURL represents an error message when the URL can't be mapped to a path.
+var errInvalidURL = errors.New("invalid URL")
+
+// URLToPath maps the URL to a relative slash separated path, e.g.
+// `https://www.example.com/docs/x?y=1` becomes `https/www.example.com/docs/x~qy%3D1`.
+//
+// The path is `<scheme>/<host>[_<port>]` followed by one element per URL path segment,
+// the query and fragment are appended to the last element. Characters other than letters, digits,
+// dot, dash and underscore are percent-escaped, a leading dot or underscore is escaped too, and empty segments
+// become `_`. So distinct URLs never map to the same path and the only path element starting with `_` is the `_`
+// of an empty segment, callers can name their own files with a `_` prefix followed by other characters,
+// e.g. `_snapshots` or `_store`, inside the directory without colliding with other URLs.
+//
+// Path elements longer than 120 bytes, and paths longer than 240 bytes, are shortened with a SHA-256 hash suffix.
+func URLToPath(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", errInvalidURL, rawURL, err)
+	}
+
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return "", fmt.Errorf("%w: %s: missing scheme or host", errInvalidURL, rawURL)
+	}
+
+	host := escapeHost(strings.ToLower(parsed.Hostname()))
+	if port := parsed.Port(); port != "" {
+		host += "_" + escapeHost(port)
+	}
+
+	elements := []string{escapeHost(strings.ToLower(parsed.Scheme)), host}
+
+	escapedPath := parsed.EscapedPath()
+
+	if escapedPath != "" && escapedPath != "/" {
+		for _, segment := range strings.Split(strings.TrimPrefix(escapedPath, "/"), "/") {
+			elements = append(elements, escapeSegment(segment))
+		}
+	}
+
+	var suffix string
+
+	if parsed.RawQuery != "" || parsed.ForceQuery {
+		suffix += queryMarker + escapeSegment(parsed.RawQuery)
+	}
+
+	if parsed.Fragment != "" {
+		suffix += fragmentMarker + escapeSegment(parsed.EscapedFragment())
+	}
+
+	if suffix != "" {
+		if len(elements) == 2 {
+			elements = append(elements, suffix)
+		} else {
+			elements[len(elements)-1] += suffix
+		}
+	}
+
+	for i := range elements {
+		elements[i] = shortenElement(elements[i])
+	}
+
+	return shortenPath(elements), nil
+}
+
+// escapeSegment unescapes the URL path segment and escapes it again to be a safe path element.
+func escapeSegment(segment string) string {
+	if segment == "" {
+		return emptySegment
+	}
+
+	if unescaped, err := url.PathUnescape(segment); err == nil {
+		segment = unescaped
+	}
+
+	return escape(segment, true)
+}
+
+// escapeHost escapes the host to be a safe path element, underscores are escaped to separate the port.
+func escapeHost(host string) string {
+	return escape(host, false)
+}
+
+// escape percent-escapes every byte other than letters, digits, dot, dash and underscore if allowed.
+// A leading dot or underscore is always escaped to avoid `.`, `..`, hidden files and the `_` prefix.
+func escape(value string, allowUnderscore bool) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+
+		switch {
+		case 'A' <= char && char <= 'Z', 'a' <= char && char <= 'z', '0' <= char && char <= '9', char == '-':
+			builder.WriteByte(char)
+		case char == '.' && i > 0:
+			builder.WriteByte(char)
+		case char == '_' && i > 0 && allowUnderscore:
+			builder.WriteByte(char)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", char)
+		}
+	}
+
+	return builder.String()
+}
+
+// shortenElement shortens the path element to maxSegmentLength with a hash suffix of the whole element.
+func shortenElement(element string) string {
+	if len(element) <= maxSegmentLength {
+		return element
+	}
+
+	return truncate(element, maxSegmentLength-len(hashMarker)-hashLength) + hashMarker + hashHex(element)
+}
+
+// shortenPath joins the elements, if the path is longer than maxPathLength the trailing elements
+// are replaced by a single element with the hash of the whole path.
+func shortenPath(elements []string) string {
+	fullPath := strings.Join(elements, "/")
+	if len(fullPath) <= maxPathLength {
+		return fullPath
+	}
+
+	hashElement := hashMarker + hashHex(fullPath)
+
+	var kept []string
+
+	length := len(hashElement)
+
+	for _, element := range elements {
+		if length+len(element)+1 > maxPathLength {
+			break
+		}
+
+		kept = append(kept, element)
+		length += len(element) + 1
+	}
+
+	return strings.Join(append(kept, hashElement), "/")
+}
+
+// truncate cuts the escaped value to at most `length` bytes without splitting a `%XX` escape sequence.
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+
+	value = value[:length]
+
+	if i := strings.LastIndexByte(value, '%'); i >= 0 && i > length-3 {
+		value = value[:i]
+	}
+
+	return value
+}
+
+// hashHex returns the first hashLength hex characters of the SHA-256 digest of the value.
+func hashHex(value string) string {
+	sum := sha256.Sum256([]byte(value))
+
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
+
+// RelativePath returns the relative slash separated path from the `fromDir` directory to the `target` file,
+// both relative to the same root, e.g. `../../_store/style.css`.
+func RelativePath(fromDir, target string) string {
+	from := splitPath(fromDir)
+	to := splitPath(target)
+
+	common := 0
+	for common < len(from) && common < len(to)-1 && from[common] == to[common] {
+		common++
+	}
+
+	elements := make([]string, 0, len(from)-common+len(to)-common)
+
+	for i := common; i < len(from); i++ {
+		elements = append(elements, "..")
+	}
+
+	elements = append(elements, to[common:]...)
+
+	return strings.Join(elements, "/")
+}
+
+// splitPath splits the slash separated path into its elements, ignoring empty and `.` elements.
+func splitPath(value string) []string {
+	var elements []string
+
+	for _, element := range strings.Split(value, "/") {
+		if element != "" && element != "." {
+			elements = append(elements, element)
+		}
+	}
+
+	return elements
+}
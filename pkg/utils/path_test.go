@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLToPath(t *testing.T) {
+	type test struct {
+		url     string
+		want    string
+		wantErr error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully map root URL": func(t *testing.T) test {
+			t.Helper()
+
+			return test{url: "https://www.example.com/", want: "https/www.example.com"}
+		},
+		"Successfully map URL with path and query": func(t *testing.T) test {
+			t.Helper()
+
+			return test{url: "https://a.com/docs/x?y=1", want: "https/a.com/docs/x~qy%3D1"}
+		},
+		"Successfully map URL with port and trailing slash": func(t *testing.T) test {
+			t.Helper()
+
+			return test{url: "http://localhost:8080/docs/", want: "http/localhost_8080/docs/_"}
+		},
+		"Successfully map URL with traversal segments": func(t *testing.T) test {
+			t.Helper()
+
+			return test{url: "https://a.com/../_private/.env", want: "https/a.com/%2E./%5Fprivate/%2Eenv"}
+		},
+		"Failed map URL without scheme": func(t *testing.T) test {
+			t.Helper()
+
+			return test{url: "example.com", wantErr: errInvalidURL}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			got, err := URLToPath(tt.url)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestURLToPathDistinct(t *testing.T) {
+	urls := []string{
+		"https://a.com/docs/x?y=1",
+		"https://a.com/docs/x%3Fy=1",
+		"http://a.com/docs/x?y=1",
+		"https://www.a.com/docs/x?y=1",
+		"https://a.com/docs/x",
+		"https://a.com/docs/x/",
+		"https://a.com/docs/x#top",
+		"https://a.com/docs/x%2Fy",
+		"https://a.com/docs/x/y",
+		"https://a.com/?q=1",
+		"https://a.com/~qq=1",
+		"https://a.com//",
+		"https://a.com/_",
+		"https://a.com:8443/",
+		"https://a.com_8443/",
+		"https://a.com/" + strings.Repeat("a", 300),
+		"https://a.com/" + strings.Repeat("a", 300) + "b",
+		"https://a.com/" + strings.Repeat("abcdefghij/", 40),
+		"https://a.com/" + strings.Repeat("abcdefghij/", 41),
+	}
+
+	seen := make(map[string]string, len(urls))
+
+	for _, url := range urls {
+		got, err := URLToPath(url)
+		require.NoError(t, err)
+
+		assert.LessOrEqual(t, len(got), maxPathLength, url)
+
+		for _, element := range strings.Split(got, "/") {
+			assert.LessOrEqual(t, len(element), maxSegmentLength, url)
+		}
+
+		if other, ok := seen[got]; ok {
+			t.Errorf("%s and %s map to the same path %s", url, other, got)
+		}
+
+		seen[got] = url
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	tests := map[string]struct {
+		fromDir string
+		target  string
+		want    string
+	}{
+		"Sibling tree":  {fromDir: "https/a.com/docs", target: "_store/sha256/ab/abc.css", want: "../../../_store/sha256/ab/abc.css"},
+		"Same dir":      {fromDir: "https/a.com", target: "https/a.com/_page.html", want: "_page.html"},
+		"Root dir":      {fromDir: ".", target: "_store/abc.css", want: "_store/abc.css"},
+		"Common prefix": {fromDir: "https/a.com/docs", target: "https/a.com/img/logo.png", want: "../img/logo.png"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, RelativePath(tt.fromDir, tt.target))
+		})
+	}
+}
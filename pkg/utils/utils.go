@@ -1,53 +1,23 @@
 package utils
 
 import (
-	"regexp"
+	"net/url"
 	"strings"
 )
 
-// URLToFilename removes the 'http://' or 'https://' and 'www.' prefixes from the URL.
-// Also remove the last "/" character if it exists
-func URLToFilename(url string) string {
-	// Compile the regular expression to remove the prefixes.
-	re := regexp.MustCompile(`https?://(www\.)?`)
-
-	filename := re.ReplaceAllString(url, "")
-
-	// Remove the last "/" character if it exists
-	if len(filename) > 0 && string(filename[len(filename)-1]) == "/" {
-		filename = filename[:len(filename)-1]
-	}
-
-	return filename
-}
-
-// AssetURLToFilename converts the URL to a filename by replacing slashes with underscores.
-func AssetURLToFilename(url string) string {
-	// Remove the 'http://' or 'https://' and 'www.' prefixes.
-	filename := strings.ReplaceAll(url, "https://", "")
-	filename = strings.ReplaceAll(filename, "http://", "")
-	filename = strings.ReplaceAll(filename, "www.", "")
-	// Replace slashes with underscores.
-	filename = strings.ReplaceAll(filename, "/", "_")
-
-	return filename
-}
-
-// WrapAssetDir will wrap URL with dir or dot (.)
-// Sometimes HTML page link can't work with ./dir format and should have /dir format
-func WrapAssetDir(dot, dir, asset string) string {
-	return dot + "/" + dir + "/" + asset
-}
-
-// WrapURL adds the path to the end of the URL, ensuring that there is a single slash between them.
-func WrapURL(url, path string) string {
-	if strings.Contains(path, "http") {
-		return path
+// ResolveURL resolves the reference of a page, e.g. the src of an asset, against the URL of the page,
+// so `/a.css`, `a.css`, `../a.css` and `//cdn.example.com/a.css` are resolved like a browser does.
+// The reference is returned as is if it or the URL can't be parsed.
+func ResolveURL(pageURL, ref string) string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ref
 	}
 
-	if len(path) > 0 && string(path[0]) != "/" {
-		url += "/"
+	target, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ref
 	}
 
-	return url + path
+	return base.ResolveReference(target).String()
 }
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveURL(t *testing.T) {
+	type test struct {
+		pageURL string
+		ref     string
+		want    string
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully resolve root-relative reference": func(t *testing.T) test {
+			t.Helper()
+
+			return test{pageURL: "https://a.com/docs/page.html", ref: "/css/style.css", want: "https://a.com/css/style.css"}
+		},
+		"Successfully resolve relative reference": func(t *testing.T) test {
+			t.Helper()
+
+			return test{pageURL: "https://a.com/docs/page.html", ref: "app.js", want: "https://a.com/docs/app.js"}
+		},
+		"Successfully resolve relative reference of directory": func(t *testing.T) test {
+			t.Helper()
+
+			return test{pageURL: "https://a.com/docs/", ref: "../logo.png", want: "https://a.com/logo.png"}
+		},
+		"Successfully resolve relative reference of host": func(t *testing.T) test {
+			t.Helper()
+
+			return test{pageURL: "https://a.com", ref: "app.js", want: "https://a.com/app.js"}
+		},
+		"Successfully resolve protocol-relative reference": func(t *testing.T) test {
+			t.Helper()
+
+			return test{pageURL: "https://a.com/", ref: "//cdn.b.com/lib.js", want: "https://cdn.b.com/lib.js"}
+		},
+		"Successfully resolve absolute reference": func(t *testing.T) test {
+			t.Helper()
+
+			return test{pageURL: "https://a.com/", ref: "http://b.com/x.png", want: "http://b.com/x.png"}
+		},
+		"Successfully return invalid reference": func(t *testing.T) test {
+			t.Helper()
+
+			return test{pageURL: "https://a.com/", ref: "%zz", want: "%zz"}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			assert.Equal(t, tt.want, ResolveURL(tt.pageURL, tt.ref))
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// errWebhookResponse represents an error message when the webhook doesn't respond with a 2xx status.
+var errWebhookResponse = errors.New("unexpected webhook response")
+
+// Event types.
+const (
+	// EventChanged is emitted when the page changed and a new snapshot is saved, including the first one.
+	EventChanged = "changed"
+	// EventUnchanged is emitted when the page didn't change since the latest snapshot.
+	EventUnchanged = "unchanged"
+	// EventError is emitted when the page can't be checked.
+	EventError = "error"
+)
+
+// Event is the result of a check of a watched page.
+type Event struct {
+	Type string    `json:"type"`
+	URL  string    `json:"url"`
+	Time time.Time `json:"time"`
+	// Snapshot is the ID of the new snapshot on change, or of the latest snapshot otherwise.
+	Snapshot         string `json:"snapshot,omitempty"`
+	PreviousSnapshot string `json:"previous_snapshot,omitempty"`
+	Hash             string `json:"hash,omitempty"`
+	PreviousHash     string `json:"previous_hash,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// Notifier sends the events of the watched pages.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// webhook POSTs the events as JSON to a URL.
+type webhook struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhook returns a Notifier POSTing every event as JSON to the URL.
+func NewWebhook(url string, httpClient *http.Client) Notifier {
+	return &webhook{url: url, httpClient: httpClient}
+}
+
+// Notify POSTs the event and expects a 2xx response.
+func (w *webhook) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %s: %s", errWebhookResponse, w.url, resp.Status)
+	}
+
+	return nil
+}
+
+// command runs a local command for every event.
+type command struct {
+	command string
+}
+
+// NewCommand returns a Notifier running the shell command for every event.
+// The event is written as JSON to the standard input of the command,
+// and its type, URL and snapshot are set in the FETCH_EVENT, FETCH_URL and FETCH_SNAPSHOT environment variables.
+func NewCommand(cmd string) Notifier {
+	return &command{command: cmd}
+}
+
+// Notify runs the command and waits for it to exit.
+func (c *command) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"FETCH_EVENT="+event.Type,
+		"FETCH_URL="+event.URL,
+		"FETCH_SNAPSHOT="+event.Snapshot,
+	)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run command: %s: %w", c.command, err)
+	}
+
+	return nil
+}
+
+// jsonLines writes the events as JSON lines.
+type jsonLines struct {
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+// NewJSONLines returns a Notifier writing every event as a JSON line, e.g. to stdout.
+func NewJSONLines(w io.Writer) Notifier {
+	return &jsonLines{writer: w}
+}
+
+// Notify writes the event, the events of concurrent checks are never interleaved.
+func (j *jsonLines) Notify(_ context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if _, err := j.writer.Write(append(body, '\n')); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+
+	return nil
+}
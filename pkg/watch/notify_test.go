@@ -0,0 +1,103 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEvent = Event{
+	Type:     EventChanged,
+	URL:      "https://example.com",
+	Time:     time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC),
+	Snapshot: "20261019T100000Z",
+	Hash:     "abcd",
+}
+
+func TestWebhook(t *testing.T) {
+	type test struct {
+		status  int
+		wantErr error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully post event": func(t *testing.T) test {
+			t.Helper()
+
+			return test{status: http.StatusNoContent}
+		},
+		"Failed webhook response": func(t *testing.T) test {
+			t.Helper()
+
+			return test{status: http.StatusInternalServerError, wantErr: errWebhookResponse}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			var got Event
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := NewWebhook(server.URL, server.Client()).Notify(context.Background(), testEvent)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testEvent, got)
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "event")
+
+	err := NewCommand(`{ echo "$FETCH_EVENT $FETCH_URL $FETCH_SNAPSHOT"; cat; } > `+output).Notify(context.Background(), testEvent)
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(output)
+	require.NoError(t, err)
+
+	event, err := json.Marshal(testEvent)
+	require.NoError(t, err)
+
+	assert.Equal(t, "changed https://example.com 20261019T100000Z\n"+string(event), string(got))
+}
+
+func TestJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+
+	notifier := NewJSONLines(&buf)
+
+	require.NoError(t, notifier.Notify(context.Background(), testEvent))
+	require.NoError(t, notifier.Notify(context.Background(), Event{Type: EventError, URL: "https://example.com", Error: "boom"}))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var got Event
+
+	require.NoError(t, json.Unmarshal(lines[0], &got))
+	assert.Equal(t, testEvent, got)
+}
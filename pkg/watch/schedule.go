@@ -0,0 +1,66 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// errInvalidSchedule represents an error message when the schedule can't be parsed.
+var errInvalidSchedule = errors.New("invalid schedule")
+
+// Schedule returns the next time to check the pages after the given time.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// every is a Schedule checking the pages on a fixed interval.
+type every time.Duration
+
+// Next returns the time after one interval.
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// Every returns a Schedule checking the pages every `interval`.
+func Every(interval time.Duration) (Schedule, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("%w: interval must be positive: %s", errInvalidSchedule, interval)
+	}
+
+	return every(interval), nil
+}
+
+// ParseCron returns a Schedule from a standard 5 fields cron expression, e.g. `*/5 * * * *`,
+// or a descriptor such as `@hourly`.
+func ParseCron(spec string) (Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errInvalidSchedule, spec, err)
+	}
+
+	return schedule, nil
+}
+
+// Run calls `check` immediately and then on every time of the schedule, until the context is done.
+// A check taking longer than the interval delays the next one, the checks never overlap.
+func Run(ctx context.Context, schedule Schedule, check func(ctx context.Context)) {
+	for {
+		check(ctx)
+
+		wait := time.Until(schedule.Next(time.Now()))
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-timer.C:
+		}
+	}
+}
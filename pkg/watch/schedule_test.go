@@ -0,0 +1,90 @@
+package watch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 2, 30, 0, time.UTC)
+
+	type test struct {
+		schedule func() (Schedule, error)
+		want     time.Time
+		wantErr  error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Successfully schedule interval": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				schedule: func() (Schedule, error) { return Every(10 * time.Minute) },
+				want:     now.Add(10 * time.Minute),
+			}
+		},
+		"Successfully schedule cron": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				schedule: func() (Schedule, error) { return ParseCron("*/5 * * * *") },
+				want:     time.Date(2026, 10, 19, 10, 5, 0, 0, time.UTC),
+			}
+		},
+		"Failed zero interval": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				schedule: func() (Schedule, error) { return Every(0) },
+				wantErr:  errInvalidSchedule,
+			}
+		},
+		"Failed invalid cron": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				schedule: func() (Schedule, error) { return ParseCron("every minute") },
+				wantErr:  errInvalidSchedule,
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			schedule, err := tt.schedule()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, schedule.Next(now))
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	schedule, err := Every(time.Millisecond)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	checks := 0
+
+	Run(ctx, schedule, func(ctx context.Context) {
+		checks++
+
+		if checks == 3 {
+			cancel()
+		}
+	})
+
+	assert.Equal(t, 3, checks)
+}
@@ -0,0 +1,78 @@
+// Package watch provides the building blocks of the watch mode:
+// the content hash to detect changes, the schedules and the notifiers of the change events.
+package watch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/moemoe89/fetch/pkg/fetcher"
+	"golang.org/x/net/html"
+)
+
+// errInvalidSelector represents an error message when a CSS selector can't be parsed.
+var errInvalidSelector = errors.New("invalid CSS selector")
+
+// Stripper removes the volatile elements of a page, e.g. timestamps or ads,
+// so they don't count as a change of the page.
+type Stripper struct {
+	selectors []cascadia.SelectorGroup
+}
+
+// NewStripper returns a Stripper removing the elements matching any of the CSS selectors.
+func NewStripper(selectors []string) (*Stripper, error) {
+	s := &Stripper{}
+
+	for _, selector := range selectors {
+		group, err := cascadia.ParseGroup(selector)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errInvalidSelector, selector, err)
+		}
+
+		s.selectors = append(s.selectors, group)
+	}
+
+	return s, nil
+}
+
+// Strip returns the page without the elements matching the selectors.
+// The page is returned as is if there is no selector.
+func (s *Stripper) Strip(body []byte) ([]byte, error) {
+	if len(s.selectors) == 0 {
+		return body, nil
+	}
+
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	for _, selector := range s.selectors {
+		for _, node := range cascadia.QueryAll(doc, selector) {
+			// The node may be already removed with a matching ancestor.
+			if node.Parent != nil {
+				node.Parent.RemoveChild(node)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := html.Render(&buf, doc); err != nil {
+		return nil, fmt.Errorf("failed to render html: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Hash returns the SHA-256 hex digest of the page after stripping the volatile elements.
+func (s *Stripper) Hash(body []byte) (string, error) {
+	stripped, err := s.Strip(body)
+	if err != nil {
+		return "", err
+	}
+
+	return fetcher.Checksum(stripped), nil
+}
@@ -0,0 +1,89 @@
+package watch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripperHash(t *testing.T) {
+	type test struct {
+		selectors []string
+		first     string
+		second    string
+		wantEqual bool
+		wantErr   error
+	}
+
+	tests := map[string]func(t *testing.T) test{
+		"Volatile element is ignored": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				selectors: []string{".ts", "#ad"},
+				first:     `<html><body><p>Status</p><p class="ts">10:00</p><div id="ad">a</div></body></html>`,
+				second:    `<html><body><p>Status</p><p class="ts">10:05</p><div id="ad">b</div></body></html>`,
+				wantEqual: true,
+			}
+		},
+		"Nested matching elements are removed once": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				selectors: []string{"div", "span"},
+				first:     `<html><body><p>Status</p><div><span>1</span></div></body></html>`,
+				second:    `<html><body><p>Status</p><div><span>2</span></div></body></html>`,
+				wantEqual: true,
+			}
+		},
+		"Content change is detected": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				selectors: []string{".ts"},
+				first:     `<html><body><p>Up</p><p class="ts">10:00</p></body></html>`,
+				second:    `<html><body><p>Down</p><p class="ts">10:00</p></body></html>`,
+			}
+		},
+		"Without selectors every byte counts": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				first:  `<p>Up</p>`,
+				second: `<p>Up</p> `,
+			}
+		},
+		"Failed invalid selector": func(t *testing.T) test {
+			t.Helper()
+
+			return test{
+				selectors: []string{"p["},
+				wantErr:   errInvalidSelector,
+			}
+		},
+	}
+
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := fn(t)
+
+			stripper, err := NewStripper(tt.selectors)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			first, err := stripper.Hash([]byte(tt.first))
+			require.NoError(t, err)
+
+			second, err := stripper.Hash([]byte(tt.second))
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantEqual, first == second)
+		})
+	}
+}